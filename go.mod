@@ -7,12 +7,14 @@ require (
 	github.com/blang/semver v3.5.1+incompatible
 	github.com/cpuguy83/go-md2man v1.0.10
 	github.com/google/go-cmp v0.7.0
+	github.com/helmfile/helmfile v1.1.3
 	github.com/jenkins-x-plugins/jx-gitops v1.3.3
 	github.com/jenkins-x-plugins/jx-pipeline v0.7.40
 	github.com/jenkins-x-plugins/jx-promote v0.6.42
 	github.com/jenkins-x/go-scm v1.15.28
 	github.com/jenkins-x/jx-api/v4 v4.8.6
 	github.com/jenkins-x/jx-helpers/v3 v3.11.6
+	github.com/jenkins-x/jx-kube-client/v3 v3.0.11
 	github.com/jenkins-x/jx-logging/v3 v3.1.6
 	github.com/jenkins-x/lighthouse-client v0.0.1944
 	github.com/shurcooL/githubv4 v0.0.0-20260209031235-2402fdf4a9ed
@@ -20,8 +22,10 @@ require (
 	github.com/spf13/pflag v1.0.10
 	github.com/stretchr/testify v1.11.1
 	github.com/yargevad/filepathx v0.0.0-20161019152617-907099cb5a62
+	golang.org/x/mod v0.37.0
 	golang.org/x/oauth2 v0.36.0
 	k8s.io/apimachinery v0.36.2
+	k8s.io/client-go v0.36.2
 	oras.land/oras-go/v2 v2.6.1
 	sigs.k8s.io/kustomize/kyaml v0.21.1
 )
@@ -212,7 +216,6 @@ require (
 	github.com/hashicorp/jsonapi v1.3.1 // indirect
 	github.com/hashicorp/vault/api v1.22.0 // indirect
 	github.com/helmfile/chartify v0.24.6 // indirect
-	github.com/helmfile/helmfile v1.1.3 // indirect
 	github.com/helmfile/vals v0.41.2 // indirect
 	github.com/huandu/xstrings v1.5.0 // indirect
 	github.com/ianlancetaylor/demangle v0.0.0-20240805132620-81f5be970eca // indirect
@@ -220,7 +223,6 @@ require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/itchyny/gojq v0.12.16 // indirect
 	github.com/itchyny/timefmt-go v0.1.6 // indirect
-	github.com/jenkins-x/jx-kube-client/v3 v3.0.11 // indirect
 	github.com/jenkins-x/logrus-stackdriver-formatter v0.2.9 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/jmoiron/sqlx v1.4.0 // indirect
@@ -326,7 +328,6 @@ require (
 	go.yaml.in/yaml/v3 v3.0.4 // indirect
 	golang.org/x/crypto v0.53.0 // indirect
 	golang.org/x/exp v0.0.0-20260603202125-055de637280b // indirect
-	golang.org/x/mod v0.37.0 // indirect
 	golang.org/x/net v0.56.0 // indirect
 	golang.org/x/sync v0.21.0 // indirect
 	golang.org/x/sys v0.46.0 // indirect
@@ -354,7 +355,6 @@ require (
 	k8s.io/api v0.36.2 // indirect
 	k8s.io/apiextensions-apiserver v0.36.2 // indirect
 	k8s.io/cli-runtime v0.36.2 // indirect
-	k8s.io/client-go v0.36.2 // indirect
 	k8s.io/component-base v0.36.2 // indirect
 	k8s.io/klog/v2 v2.140.0 // indirect
 	k8s.io/kube-openapi v0.0.0-20260603220949-865597e52e25 // indirect
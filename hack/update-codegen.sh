@@ -0,0 +1,41 @@
+#!/usr/bin/env bash
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+BIN_DIR=${BIN_DIR:-$(go env GOPATH)/bin}
+MODULE=github.com/jenkins-x-plugins/jx-updatebot
+
+cd "$(dirname "${BASH_SOURCE[0]}")/.."
+
+echo "generating deepcopy functions"
+"${BIN_DIR}/deepcopy-gen" --go-header-file hack/boilerplate.go.txt \
+  --output-file zz_generated.deepcopy.go \
+  ./pkg/apis/updatebot/v1alpha1
+
+echo "generating clientset"
+"${BIN_DIR}/client-gen" --go-header-file hack/boilerplate.go.txt \
+  --clientset-name versioned \
+  --input-base "" \
+  --input ${MODULE}/pkg/apis/updatebot/v1alpha1 \
+  --output-dir pkg/client/clientset \
+  --output-pkg ${MODULE}/pkg/client/clientset
+
+echo "generating listers"
+"${BIN_DIR}/lister-gen" --go-header-file hack/boilerplate.go.txt \
+  --output-dir pkg/client/listers \
+  --output-pkg ${MODULE}/pkg/client/listers \
+  ./pkg/apis/updatebot/v1alpha1
+
+echo "generating informers"
+"${BIN_DIR}/informer-gen" --go-header-file hack/boilerplate.go.txt \
+  --versioned-clientset-package ${MODULE}/pkg/client/clientset/versioned \
+  --listers-package ${MODULE}/pkg/client/listers \
+  --output-dir pkg/client/informers \
+  --output-pkg ${MODULE}/pkg/client/informers \
+  ./pkg/apis/updatebot/v1alpha1
+
+echo "generating CRDs"
+rm -rf crds
+"${BIN_DIR}/controller-gen" crd:crdVersions=v1 paths=./pkg/apis/... output:crd:dir=./crds
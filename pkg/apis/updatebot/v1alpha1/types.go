@@ -8,6 +8,8 @@ import (
 // +genclient
 // +genclient:noStatus
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:categories=jx
 
 // UpdateConfig defines the update rules
 //
@@ -21,21 +23,94 @@ type UpdateConfig struct {
 	Spec UpdateConfigSpec `json:"spec"`
 }
 
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+
+// UpdateConfigList contains a list of UpdateConfig
+type UpdateConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []UpdateConfig `json:"items"`
+}
+
 // UpdateConfigSpec defines the rules to perform when updating.
 type UpdateConfigSpec struct {
+	// SourceURLs the git URLs of the upstream repositories whose releases trigger this configuration
+	// when running as a controller
+	SourceURLs []string `json:"sourceURLs,omitempty"`
+
+	// Includes other UpdateConfig files to merge into this configuration. Each entry is either a path relative to
+	// this file or a file in a git repository of the form `gitURL@ref:path`. Values in this file take precedence over
+	// any included values
+	Includes []string `json:"includes,omitempty"`
+
+	// RuleTemplates defines named rules which can be reused and parameterised by the Rules
+	RuleTemplates []RuleTemplate `json:"ruleTemplates,omitempty"`
+
 	// PullRequestLabels defines the labels to apply to created pull requests
 	PullRequestLabels []string `json:"pullRequestLabels,omitempty"`
 
 	// Rules defines the change rules
 	Rules []Rule `json:"rules,omitempty"`
+
+	// Upstreams the upstream dependencies whose latest versions are checked by `jx updatebot scan`
+	Upstreams []Upstream `json:"upstreams,omitempty"`
+}
+
+// Upstream an upstream dependency whose latest version is applied to the downstream repositories when scanning.
+// Only one of GitURL, Chart, Image or GoModule should be specified
+type Upstream struct {
+	// Name the name of the upstream used in pull request titles. Defaults to the git URL, chart, image or go module
+	Name string `json:"name,omitempty"`
+
+	// GitURL the git repository whose latest release tag is used. Any `v` prefix on the tag is removed
+	GitURL string `json:"gitURL,omitempty"`
+
+	// Chart the name of the helm chart whose latest version is used
+	Chart string `json:"chart,omitempty"`
+
+	// ChartRepository the URL of the helm chart repository. Can be an `oci://` URL
+	ChartRepository string `json:"chartRepository,omitempty"`
+
+	// Image the container image whose latest semantic version tag is used
+	Image string `json:"image,omitempty"`
+
+	// GoModule the go module whose latest version is used
+	GoModule string `json:"goModule,omitempty"`
+
+	// Rules the rules to apply with the latest version. Defaults to the rules of the UpdateConfig
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// RuleTemplate a named rule which can be reused across rules and configuration files
+type RuleTemplate struct {
+	// Name the name of the template which rules refer to
+	Name string `json:"name"`
+
+	// Parameters the default values of the template parameters
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// Rule the rule to apply. Any text of the form `${name}` is replaced with the value of the parameter of that name
+	// and any text of the form `$${name}` is replaced with `${name}` so that shell variables can be used in commands
+	Rule Rule `json:"rule"`
 }
 
 // Rule specifies a set of repositories and changes
 type Rule struct {
+	// Template the name of the RuleTemplate this rule is based on. The URLs of the rule replace those of the template
+	// and the changes of the rule are added to those of the template
+	Template string `json:"template,omitempty"`
+
+	// Parameters the parameter values to pass to the template
+	Parameters map[string]string `json:"parameters,omitempty"`
+
 	// URLs the git URLs of the repositories to create a Pull Request on
+	// +optional
 	URLs []string `json:"urls"`
 
 	// Changes the changes to perform on the repositories
+	// +optional
 	Changes []Change `json:"changes"`
 
 	// Fork if we should create the pull request from a fork of the repository
@@ -120,7 +195,7 @@ func (p *Pattern) Matches(text string) bool {
 
 // VersionStreamChange for upgrading versions in a version stream
 type VersionStreamChange struct {
-	Pattern
+	Pattern `json:",inline"`
 
 	// Kind the kind of resources to change (charts, git, package etc)
 	Kind string `json:"kind,omitempty"`
@@ -143,3 +218,105 @@ type GoChange struct {
 	// NoPatch disables patch upgrades so we can import to new minor releases
 	NoPatch bool `json:"noPatch,omitempty"`
 }
+
+// Promotion defines the ordered stages that versions are promoted through by `jx updatebot promote-chain`
+type Promotion struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Spec holds the promotion specification
+	Spec PromotionSpec `json:"spec"`
+}
+
+// PromotionSpec defines the stages of a promotion
+type PromotionSpec struct {
+	// Labels the labels added to the Pull Requests created when promoting into a stage. They are used to find the
+	// Pull Requests of a stage which have not merged yet. Defaults to promote-chain
+	Labels []string `json:"labels,omitempty"`
+
+	// Stages the ordered stages. The first stage is the source of the versions and is not promoted into
+	Stages []PromotionStage `json:"stages,omitempty"`
+}
+
+// PromotionStage a git repository in a promotion which is synchronized with the versions of the previous stage
+type PromotionStage struct {
+	// Name the name of the stage
+	Name string `json:"name,omitempty"`
+
+	// Kind the kind of git repository: helmfile, argo, flux or kustomize. Defaults to helmfile
+	Kind string `json:"kind,omitempty"`
+
+	// GitURL the git URL of the repository of the stage
+	GitURL string `json:"gitUrl,omitempty"`
+
+	// Environment the name of the jx environment for a helmfile stage
+	Environment string `json:"environment,omitempty"`
+
+	// Namespace the namespace of the charts for a helmfile stage
+	Namespace string `json:"namespace,omitempty"`
+
+	// Labels the additional labels to add to the Pull Requests promoting into this stage
+	Labels []string `json:"labels,omitempty"`
+
+	// AutoMerge whether the Pull Requests promoting into this stage should be merged automatically. Defaults to true
+	AutoMerge *bool `json:"autoMerge,omitempty"`
+
+	// Gate the gate of this stage which must pass before its versions are promoted into the next stage. The Pull
+	// Requests promoting into this stage must always be merged first
+	Gate PromotionGate `json:"gate,omitempty"`
+}
+
+// PromotionGate the conditions on a stage which must pass before its versions are promoted into the next stage
+type PromotionGate struct {
+	// Soak the minimum duration since the last Pull Request merged into the stage. e.g. 30m or 24h
+	Soak string `json:"soak,omitempty"`
+
+	// HealthCheck a command which must succeed
+	HealthCheck *Command `json:"healthCheck,omitempty"`
+}
+
+// SyncPolicy defines the policies that source versions must pass to be synchronized by `jx updatebot sync`
+type SyncPolicy struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Spec holds the sync policy specification
+	Spec SyncPolicySpec `json:"spec"`
+}
+
+// SyncPolicySpec defines the version policies of the charts
+type SyncPolicySpec struct {
+	// Default the policy of the charts which do not match any of the chart policies
+	Default VersionPolicy `json:"default,omitempty"`
+
+	// Charts the policies of specific charts. The first matching policy is used
+	Charts []ChartPolicy `json:"charts,omitempty"`
+}
+
+// ChartPolicy the version policy of a chart
+type ChartPolicy struct {
+	VersionPolicy `json:",inline"`
+
+	// Chart the name of the chart with or without the repository prefix. e.g. dev/myapp or myapp
+	Chart string `json:"chart,omitempty"`
+
+	// Namespace the namespace of the chart. If not specified the policy applies to all namespaces
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// VersionPolicy the rules that a source version must pass to be synchronized
+type VersionPolicy struct {
+	// MinAge the minimum time since the version was committed to the source repository. e.g. 24h
+	MinAge string `json:"minAge,omitempty"`
+
+	// MaxJump the largest semantic version change allowed: patch, minor or major
+	MaxJump string `json:"maxJump,omitempty"`
+
+	// DenyPrerelease prevents synchronizing prerelease versions. e.g. 1.2.3-rc.1
+	DenyPrerelease bool `json:"denyPrerelease,omitempty"`
+
+	// Pinned prevents the chart from ever being changed
+	Pinned bool `json:"pinned,omitempty"`
+}
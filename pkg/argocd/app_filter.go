@@ -1,29 +1,58 @@
 package argocd
 
 import (
+	"fmt"
+
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/spf13/cobra"
 )
 
 // AppFilter filter for apps
 type AppFilter struct {
-	RepoURL gitops.TextFilter
-	Path    gitops.TextFilter
+	RepoURL              gitops.TextFilter
+	Path                 gitops.TextFilter
+	Chart                gitops.TextFilter
+	Project              gitops.TextFilter
+	DestinationNamespace gitops.TextFilter
+	DestinationServer    gitops.TextFilter
+	Labels               gitops.LabelFilter
 }
 
 // Matches return true if the app version matches the filter
 func (o *AppFilter) Matches(v *AppVersion) bool {
-	if !stringhelpers.StringContainsAny(v.RepoURL, o.RepoURL.Includes, o.RepoURL.Excludes) {
-		return false
-	}
-	if !stringhelpers.StringContainsAny(v.Path, o.Path.Includes, o.Path.Excludes) {
-		return false
+	return o.RepoURL.Matches(v.RepoURL) &&
+		o.Path.Matches(v.Path) &&
+		o.Chart.Matches(v.Chart) &&
+		o.Project.Matches(v.Project) &&
+		o.DestinationNamespace.Matches(v.DestinationNamespace) &&
+		o.DestinationServer.Matches(v.DestinationServer) &&
+		o.Labels.Matches(v.Labels)
+}
+
+// Validate validates the filter patterns
+func (o *AppFilter) Validate() error {
+	for name, f := range map[string]*gitops.TextFilter{
+		"repourl":               &o.RepoURL,
+		"path":                  &o.Path,
+		"chart":                 &o.Chart,
+		"project":               &o.Project,
+		"destination-namespace": &o.DestinationNamespace,
+		"destination-server":    &o.DestinationServer,
+	} {
+		err := f.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid %s filter: %w", name, err)
+		}
 	}
-	return true
+	return o.Labels.Validate()
 }
 
 func (o *AppFilter) AddFlags(cmd *cobra.Command) {
 	o.RepoURL.AddFlags(cmd, "repourl", "repository URL")
-	o.RepoURL.AddFlags(cmd, "path", "path of the helm chart")
+	o.Path.AddFlags(cmd, "path", "path of the helm chart")
+	o.Chart.AddFlags(cmd, "chart", "name of the helm chart")
+	o.Project.AddFlags(cmd, "project", "project of the application")
+	o.DestinationNamespace.AddFlags(cmd, "destination-namespace", "destination namespace of the application")
+	o.DestinationServer.AddFlags(cmd, "destination-server", "destination server URL or cluster name of the application")
+	o.Labels.AddFlags(cmd, "applications")
 }
@@ -46,6 +46,40 @@ func TestAppFilter(t *testing.T) {
 				{RepoURL: "https://github.com/myorg/app1", Path: "cheese"},
 			},
 		},
+		{
+			filter: argocd.AppFilter{
+				Chart: gitops.TextFilter{
+					Includes: []string{"my-chart"},
+				},
+			},
+			matches: []argocd.AppVersion{
+				{RepoURL: "https://charts.myorg.io", Chart: "my-chart"},
+			},
+			notMatches: []argocd.AppVersion{
+				{RepoURL: "https://charts.myorg.io", Chart: "other-chart"},
+			},
+		},
+		{
+			filter: argocd.AppFilter{
+				Project: gitops.TextFilter{
+					Includes: []string{"regex:^team-"},
+				},
+				DestinationNamespace: gitops.TextFilter{
+					Excludes: []string{"glob:kube-*"},
+				},
+				Labels: gitops.LabelFilter{
+					Selector: "tier=web",
+				},
+			},
+			matches: []argocd.AppVersion{
+				{RepoURL: "https://github.com/myorg/app1", Project: "team-a", DestinationNamespace: "web", Labels: map[string]string{"tier": "web"}},
+			},
+			notMatches: []argocd.AppVersion{
+				{RepoURL: "https://github.com/myorg/app1", Project: "default", DestinationNamespace: "web", Labels: map[string]string{"tier": "web"}},
+				{RepoURL: "https://github.com/myorg/app1", Project: "team-a", DestinationNamespace: "kube-system", Labels: map[string]string{"tier": "web"}},
+				{RepoURL: "https://github.com/myorg/app1", Project: "team-a", DestinationNamespace: "web"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,196 @@
+package argocd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SetHelmParameter sets the value of the named helm.parameters entry of the source adding it if it is missing
+func SetHelmParameter(source *yaml.RNode, path, name, value string) error {
+	err := source.PipeE(
+		yaml.LookupCreate(yaml.MappingNode, "helm", "parameters", "[name="+name+"]"),
+		yaml.SetField("value", yaml.NewStringRNode(value)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set helm parameter %s to %s: %w", name, value, err)
+	}
+	log.Logger().Debugf("modified the helm parameter %s in file %s to %s", name, path, value)
+	return nil
+}
+
+// SetHelmValue sets the dot separated path in the helm.valuesObject of the source. If the source uses the
+// helm.values string instead then the path is modified inside the string
+func SetHelmValue(source *yaml.RNode, path, valuePath, value string) error {
+	fields := strings.Split(valuePath, ".")
+
+	valuesText := ""
+	valuesNode, err := source.Pipe(yaml.Lookup("helm", "values"))
+	if err != nil {
+		return fmt.Errorf("failed to find helm.values in file %s: %w", path, err)
+	}
+	if valuesNode != nil {
+		valuesText = valuesNode.YNode().Value
+	}
+	if strings.TrimSpace(valuesText) == "" {
+		err := source.PipeE(
+			yaml.LookupCreate(yaml.MappingNode, append([]string{"helm", "valuesObject"}, fields[:len(fields)-1]...)...),
+			yaml.SetField(fields[len(fields)-1], yaml.NewStringRNode(value)),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set helm.valuesObject.%s to %s: %w", valuePath, value, err)
+		}
+		log.Logger().Debugf("modified the helm value %s in file %s to %s", valuePath, path, value)
+		return nil
+	}
+
+	values, err := yaml.Parse(valuesText)
+	if err != nil {
+		return fmt.Errorf("failed to parse helm.values in file %s: %w", path, err)
+	}
+	err = values.PipeE(
+		yaml.LookupCreate(yaml.MappingNode, fields[:len(fields)-1]...),
+		yaml.SetField(fields[len(fields)-1], yaml.NewStringRNode(value)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set %s in helm.values to %s: %w", valuePath, value, err)
+	}
+	valuesText, err = values.String()
+	if err != nil {
+		return fmt.Errorf("failed to marshal helm.values in file %s: %w", path, err)
+	}
+
+	valuesNode = yaml.NewStringRNode(valuesText)
+	valuesNode.YNode().Style = yaml.LiteralStyle
+	err = source.PipeE(yaml.Lookup("helm"), yaml.SetField("values", valuesNode))
+	if err != nil {
+		return fmt.Errorf("failed to set helm.values in file %s: %w", path, err)
+	}
+	log.Logger().Debugf("modified the helm value %s in file %s to %s", valuePath, path, value)
+	return nil
+}
+
+// SetKustomizeImage sets the tag of the image in the kustomize.images of the source adding it if it is missing
+func SetKustomizeImage(source *yaml.RNode, path, image, tag string) error {
+	images, err := source.Pipe(yaml.LookupCreate(yaml.SequenceNode, "kustomize", "images"))
+	if err != nil {
+		return fmt.Errorf("failed to find kustomize.images in file %s: %w", path, err)
+	}
+
+	elements, err := images.Elements()
+	if err != nil {
+		return fmt.Errorf("failed to read kustomize.images in file %s: %w", path, err)
+	}
+	for _, e := range elements {
+		text := kyamls.TrimSpaceAndQuotes(e.YNode().Value)
+		if KustomizeImageName(text) != image {
+			continue
+		}
+		e.YNode().Value = kustomizeImageWithoutTag(text) + ":" + tag
+		log.Logger().Debugf("modified the kustomize image %s in file %s to %s", image, path, tag)
+		return nil
+	}
+
+	err = images.PipeE(yaml.Append(yaml.NewStringRNode(image + ":" + tag).YNode()))
+	if err != nil {
+		return fmt.Errorf("failed to add kustomize image %s in file %s: %w", image, path, err)
+	}
+	log.Logger().Debugf("added the kustomize image %s in file %s with tag %s", image, path, tag)
+	return nil
+}
+
+// KustomizeImageName returns the name of the image being overridden in a kustomize images entry
+// of the form name[=newName][:tag][@digest]
+func KustomizeImageName(text string) string {
+	if i := strings.Index(text, "="); i >= 0 {
+		return text[:i]
+	}
+	return kustomizeImageWithoutTag(text)
+}
+
+// kustomizeImageWithoutTag removes any tag or digest from the kustomize images entry
+func kustomizeImageWithoutTag(text string) string {
+	if i := strings.Index(text, "@"); i >= 0 {
+		text = text[:i]
+	}
+	if i := strings.LastIndex(text, ":"); i > strings.LastIndex(text, "/") {
+		text = text[:i]
+	}
+	return text
+}
+
+// SetAppSetElementField sets the field of the list generator elements of the ApplicationSet which match all the
+// selector fields. Elements of list generators nested inside matrix and merge generators are also modified.
+// Returns the number of elements modified
+func SetAppSetElementField(node *yaml.RNode, path, field string, selector map[string]string, value string) (int, error) {
+	generators, err := node.Pipe(yaml.Lookup("spec", "generators"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find spec.generators in file %s: %w", path, err)
+	}
+	count, err := setGeneratorElementsField(generators, path, field, selector, value)
+	if err != nil {
+		return count, err
+	}
+	log.Logger().Debugf("modified %d elements field %s in file %s to %s", count, field, path, value)
+	return count, nil
+}
+
+func setGeneratorElementsField(generators *yaml.RNode, path, field string, selector map[string]string, value string) (int, error) {
+	if generators == nil {
+		return 0, nil
+	}
+	list, err := generators.Elements()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read generators in file %s: %w", path, err)
+	}
+
+	count := 0
+	for _, generator := range list {
+		elements, err := generator.Pipe(yaml.Lookup("list", "elements"))
+		if err != nil {
+			return count, fmt.Errorf("failed to find list.elements in file %s: %w", path, err)
+		}
+		if elements != nil {
+			items, err := elements.Elements()
+			if err != nil {
+				return count, fmt.Errorf("failed to read list.elements in file %s: %w", path, err)
+			}
+			for _, item := range items {
+				if !elementMatches(item, path, selector) {
+					continue
+				}
+				err = item.PipeE(yaml.SetField(field, yaml.NewStringRNode(value)))
+				if err != nil {
+					return count, fmt.Errorf("failed to set element field %s in file %s: %w", field, path, err)
+				}
+				count++
+			}
+		}
+
+		for _, kind := range []string{"matrix", "merge"} {
+			nested, err := generator.Pipe(yaml.Lookup(kind, "generators"))
+			if err != nil {
+				return count, fmt.Errorf("failed to find %s.generators in file %s: %w", kind, path, err)
+			}
+			n, err := setGeneratorElementsField(nested, path, field, selector, value)
+			count += n
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
+
+func elementMatches(element *yaml.RNode, path string, selector map[string]string) bool {
+	for k, v := range selector {
+		if kyamls.GetStringField(element, path, k) != v {
+			return false
+		}
+	}
+	return true
+}
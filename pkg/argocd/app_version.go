@@ -12,14 +12,19 @@ import (
 
 // AppVersion represents an app version metadata from an ArgoCD Application
 type AppVersion struct {
-	RepoURL string
-	Version string
-	Path    string
+	RepoURL              string
+	Version              string
+	Path                 string
+	Chart                string
+	Project              string
+	DestinationNamespace string
+	DestinationServer    string
+	Labels               map[string]string
 }
 
 // Key returns a unique key for the app version
 func (v *AppVersion) Key() string {
-	return gitops.TrimGitURLSuffix(v.RepoURL) + "\n" + v.Path
+	return gitops.TrimGitURLSuffix(v.RepoURL) + "\n" + v.Path + "\n" + v.Chart
 }
 
 // String returns the string summary of the app version
@@ -28,48 +33,155 @@ func (v *AppVersion) String() string {
 	if v.Path != "" {
 		sep = " path: " + v.Path
 	}
+	if v.Chart != "" {
+		sep += " chart: " + v.Chart
+	}
 	return "repo: " + v.RepoURL + sep + " version: " + v.Version
 }
 
 // GetRepoURL gets repository URL
 func GetRepoURL(node *yaml.RNode, path string) string {
-
 	annotation := kyamls.GetStringField(node, path, "metadata", "annotations", "gitops.jenkins-x.io/sourceRepoUrl")
-	repoURL := kyamls.GetStringField(node, path, "spec", "source", "repoURL")
 	if annotation != "" {
 		return annotation
 	}
-	if repoURL != "" {
-		return repoURL
+	for _, source := range GetSources(node, path) {
+		repoURL := kyamls.GetStringField(source, path, "repoURL")
+		if repoURL != "" {
+			return repoURL
+		}
 	}
 	return ""
 }
 
-// GetAppVersion gets the AppVersion from the given YAML file
-func GetAppVersion(node *yaml.RNode, path string) *AppVersion {
+// GetSources returns the spec.sources entries of the Application or ApplicationSet template
+// or the spec.source if there are no spec.sources
+func GetSources(node *yaml.RNode, path string) []*yaml.RNode {
+	specFields := getSpecFields(node, path)
+
+	sources, err := node.Pipe(yaml.Lookup(append(specFields, "sources")...))
+	if err != nil {
+		log.Logger().Debugf("failed to read field %s for path %s", kyamls.JSONPath(append(specFields, "sources")...), path)
+	}
+	if sources != nil {
+		elements, err := sources.Elements()
+		if err != nil {
+			log.Logger().Warnf("failed to read the elements of %s for path %s", kyamls.JSONPath(append(specFields, "sources")...), path)
+		}
+		if len(elements) > 0 {
+			return elements
+		}
+	}
+
+	source, err := node.Pipe(yaml.Lookup(append(specFields, "source")...))
+	if err != nil {
+		log.Logger().Debugf("failed to read field %s for path %s", kyamls.JSONPath(append(specFields, "source")...), path)
+	}
+	if source == nil {
+		return nil
+	}
+	return []*yaml.RNode{source}
+}
+
+// getSpecFields returns the path to the Application spec which is inside the template for an ApplicationSet
+func getSpecFields(node *yaml.RNode, path string) []string {
+	if kyamls.GetKind(node, path) == "ApplicationSet" {
+		return []string{"spec", "template", "spec"}
+	}
+	return []string{"spec"}
+}
+
+// IsRefSource returns true if the source is only a ref: source used for value files of the other sources
+func IsRefSource(source *yaml.RNode, path string) bool {
+	return kyamls.GetStringField(source, path, "ref") != "" &&
+		kyamls.GetStringField(source, path, "path") == "" &&
+		kyamls.GetStringField(source, path, "chart") == ""
+}
+
+// GetSourceVersion gets the AppVersion of the given source
+func GetSourceVersion(source *yaml.RNode, path string) *AppVersion {
 	v := &AppVersion{}
-	v.RepoURL = kyamls.GetStringField(node, path, "spec", "source", "repoURL")
-	v.Path = kyamls.GetStringField(node, path, "spec", "source", "path")
-	v.Version = kyamls.GetStringField(node, path, "spec", "source", "targetRevision")
+	v.RepoURL = kyamls.GetStringField(source, path, "repoURL")
+	v.Path = kyamls.GetStringField(source, path, "path")
+	v.Chart = kyamls.GetStringField(source, path, "chart")
+	v.Version = kyamls.GetStringField(source, path, "targetRevision")
 	return v
 }
 
-// SetAppSetVersion sets the applicationSet version
-func SetAppSetVersion(node *yaml.RNode, path, version string) error {
-	err := node.PipeE(yaml.LookupCreate(yaml.ScalarNode, "spec", "template", "spec", "source", "targetRevision"), yaml.FieldSetter{StringValue: version})
+// GetAppSourceVersion gets the AppVersion of the source of the Application or ApplicationSet along with
+// the project, destination and labels of the Application
+func GetAppSourceVersion(node, source *yaml.RNode, path string) *AppVersion {
+	v := GetSourceVersion(source, path)
+	specFields := getSpecFields(node, path)
+	v.Project = kyamls.GetStringField(node, path, append(specFields, "project")...)
+	v.DestinationNamespace = kyamls.GetStringField(node, path, append(specFields, "destination", "namespace")...)
+	v.DestinationServer = kyamls.GetStringField(node, path, append(specFields, "destination", "server")...)
+	if v.DestinationServer == "" {
+		v.DestinationServer = kyamls.GetStringField(node, path, append(specFields, "destination", "name")...)
+	}
+	v.Labels = node.GetLabels()
+	return v
+}
+
+// GetAppVersions gets the AppVersion of each source of the given YAML file ignoring ref: sources
+func GetAppVersions(node *yaml.RNode, path string) []*AppVersion {
+	var answer []*AppVersion
+	for _, source := range GetSources(node, path) {
+		if !IsRefSource(source, path) {
+			answer = append(answer, GetAppSourceVersion(node, source, path))
+		}
+	}
+	return answer
+}
+
+// GetAppVersion gets the AppVersion of the first source from the given YAML file
+func GetAppVersion(node *yaml.RNode, path string) *AppVersion {
+	versions := GetAppVersions(node, path)
+	if len(versions) == 0 {
+		return &AppVersion{}
+	}
+	return versions[0]
+}
+
+// SetSourceVersion sets the version of the given source
+func SetSourceVersion(source *yaml.RNode, path, version string) error {
+	err := source.PipeE(yaml.LookupCreate(yaml.ScalarNode, "targetRevision"), yaml.FieldSetter{StringValue: version})
 	if err != nil {
-		return fmt.Errorf("failed to set spec.generators.template.source.targetRevision to %s: %w", version, err)
+		return fmt.Errorf("failed to set targetRevision to %s: %w", version, err)
 	}
 	log.Logger().Debugf("modified the version in file %s to %s", path, version)
 	return nil
 }
 
+// SetAppSetVersion sets the applicationSet version
+func SetAppSetVersion(node *yaml.RNode, path, version string) error {
+	return setSourcesVersion(node, path, version, "spec", "template", "spec", "source", "targetRevision")
+}
+
 // SetAppVersion sets the application version
 func SetAppVersion(node *yaml.RNode, path, version string) error {
-	err := node.PipeE(yaml.LookupCreate(yaml.ScalarNode, "spec", "source", "targetRevision"), yaml.FieldSetter{StringValue: version})
-	if err != nil {
-		return fmt.Errorf("failed to set spec.source.targetRevision to %s: %w", version, err)
+	return setSourcesVersion(node, path, version, "spec", "source", "targetRevision")
+}
+
+// setSourcesVersion sets the version of every source other than ref: sources or creates the field if there are no sources
+func setSourcesVersion(node *yaml.RNode, path, version string, fields ...string) error {
+	sources := GetSources(node, path)
+	if len(sources) == 0 {
+		err := node.PipeE(yaml.LookupCreate(yaml.ScalarNode, fields...), yaml.FieldSetter{StringValue: version})
+		if err != nil {
+			return fmt.Errorf("failed to set %s to %s: %w", kyamls.JSONPath(fields...), version, err)
+		}
+		log.Logger().Debugf("modified the version in file %s to %s", path, version)
+		return nil
+	}
+	for _, source := range sources {
+		if IsRefSource(source, path) {
+			continue
+		}
+		err := SetSourceVersion(source, path, version)
+		if err != nil {
+			return err
+		}
 	}
-	log.Logger().Debugf("modified the version in file %s to %s", path, version)
 	return nil
 }
@@ -0,0 +1,171 @@
+package changelog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/versionstream"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// Change a version change of a release or application in a Pull Request
+type Change struct {
+	Namespace string
+	Name      string
+	Chart     string
+	From      string
+	To        string
+	GitURL    string
+
+	// Tag the git tag of the new version if it is known to exist in the source repository
+	Tag string
+}
+
+// SourceURL returns the URL of the source repository of the chart or application
+func (c *Change) SourceURL() string {
+	return gitops.TrimGitURLSuffix(c.GitURL)
+}
+
+// ReleaseNotesURL returns the URL of the release notes of the new version if its git tag is known
+func (c *Change) ReleaseNotesURL() string {
+	if !HasReleaseNotes(c.GitURL) || c.Tag == "" {
+		return ""
+	}
+	return c.SourceURL() + "/releases/tag/" + c.Tag
+}
+
+// HasReleaseNotes returns true if release notes can be linked for the tags of the git repository
+func HasReleaseNotes(gitURL string) bool {
+	return strings.Contains(gitops.TrimGitURLSuffix(gitURL), "github.com/")
+}
+
+// ResolveTag returns the git tag of the version in the git repository trying the version and then the version
+// with a v prefix or an empty string if neither tag exists
+func ResolveTag(runner cmdrunner.CommandRunner, gitURL, version string) (string, error) {
+	version = VersionTag(version)
+	if gitURL == "" || version == "" {
+		return "", nil
+	}
+	candidates := []string{version}
+	if !strings.HasPrefix(version, "v") {
+		candidates = append(candidates, "v"+version)
+	}
+	args := []string{"ls-remote", "--tags", "--refs", gitURL}
+	for _, tag := range candidates {
+		args = append(args, "refs/tags/"+tag)
+	}
+	c := &cmdrunner.Command{
+		Name: "git",
+		Args: args,
+		Env: map[string]string{
+			"GIT_TERMINAL_PROMPT": "0",
+		},
+	}
+	text, err := runner(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", c.CLI(), err)
+	}
+	tags := map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			tags[strings.TrimPrefix(fields[1], "refs/tags/")] = true
+		}
+	}
+	for _, tag := range candidates {
+		if tags[tag] {
+			return tag, nil
+		}
+	}
+	return "", nil
+}
+
+// VersionTag returns the version if it is an exact version which can be used as a git tag or an empty string
+// if it is a branch or a semver range
+func VersionTag(version string) string {
+	if _, err := semver.ParseTolerant(version); err != nil {
+		return ""
+	}
+	return version
+}
+
+// ChartGitURL returns the git URL of the source of the chart from the version stream or an empty string if it is unknown
+func ChartGitURL(versionStreamDir, chart string) string {
+	if versionStreamDir == "" || chart == "" {
+		return ""
+	}
+	sv, err := versionstream.LoadStableVersion(versionStreamDir, versionstream.KindChart, chart)
+	if err != nil {
+		log.Logger().Debugf("failed to load the version stream data for chart %s: %s", chart, err.Error())
+		return ""
+	}
+	return sv.GitURL
+}
+
+// Markdown returns the markdown of the Pull Request body describing the changes grouped by namespace
+func Markdown(changes []*Change) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	byNamespace := map[string][]*Change{}
+	for _, c := range changes {
+		byNamespace[c.Namespace] = append(byNamespace[c.Namespace], c)
+	}
+	var namespaces []string
+	for ns := range byNamespace {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	buf := strings.Builder{}
+	buf.WriteString("Synchronized the following versions:\n")
+	for _, ns := range namespaces {
+		heading := "no namespace"
+		if ns != "" {
+			heading = "namespace `" + ns + "`"
+		}
+		buf.WriteString(fmt.Sprintf("\n### %s\n\n", heading))
+		buf.WriteString("| Name | Chart | Version | Links |\n")
+		buf.WriteString("| --- | --- | --- | --- |\n")
+
+		items := byNamespace[ns]
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Name < items[j].Name
+		})
+		for _, c := range items {
+			version := c.From + " → " + c.To
+			if c.From == "" {
+				version = "added " + c.To
+			}
+			buf.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", c.Name, c.Chart, version, c.links()))
+		}
+	}
+	return buf.String()
+}
+
+func (c *Change) links() string {
+	var links []string
+	if u := c.SourceURL(); u != "" {
+		links = append(links, "[source]("+u+")")
+	}
+	if u := c.ReleaseNotesURL(); u != "" {
+		links = append(links, "[release notes]("+u+")")
+	}
+	return strings.Join(links, " ")
+}
+
+// JoinSections joins the non blank markdown sections of a Pull Request body
+func JoinSections(sections ...string) string {
+	var answer []string
+	for _, text := range sections {
+		text = strings.TrimSpace(text)
+		if text != "" {
+			answer = append(answer, text)
+		}
+	}
+	return strings.Join(answer, "\n\n")
+}
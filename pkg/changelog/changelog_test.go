@@ -0,0 +1,92 @@
+package changelog_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/changelog"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdown(t *testing.T) {
+	versionStreamDir := t.TempDir()
+	path := filepath.Join(versionStreamDir, "charts", "dev", "myapp", "defaults.yaml")
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create dir for %s", path)
+	err = os.WriteFile(path, []byte("gitUrl: https://github.com/myorg/myapp.git\n"), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", path)
+
+	assert.Equal(t, "https://github.com/myorg/myapp.git", changelog.ChartGitURL(versionStreamDir, "dev/myapp"))
+	assert.Equal(t, "", changelog.ChartGitURL(versionStreamDir, "dev/unknown"))
+
+	changes := []*changelog.Change{
+		{Namespace: "jx-production", Name: "myapp", Chart: "dev/myapp", From: "1.0.0", To: "1.2.0", GitURL: changelog.ChartGitURL(versionStreamDir, "dev/myapp"), Tag: "v1.2.0"},
+		{Namespace: "jx-production", Name: "another", Chart: "dev/another", To: "2.0.0"},
+		{Namespace: "jx-canary", Name: "cheese", Chart: "dev/cheese", From: "0.1.0", To: "0.2.0", GitURL: "https://gitlab.com/myorg/cheese"},
+		{Namespace: "jx-canary", Name: "wine", Chart: "GitRepository", From: "1.0.0", To: ">=1.0.0", GitURL: "https://github.com/myorg/wine"},
+	}
+	expected := "Synchronized the following versions:\n" +
+		"\n### namespace `jx-canary`\n\n" +
+		"| Name | Chart | Version | Links |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| cheese | dev/cheese | 0.1.0 → 0.2.0 | [source](https://gitlab.com/myorg/cheese) |\n" +
+		"| wine | GitRepository | 1.0.0 → >=1.0.0 | [source](https://github.com/myorg/wine) |\n" +
+		"\n### namespace `jx-production`\n\n" +
+		"| Name | Chart | Version | Links |\n" +
+		"| --- | --- | --- | --- |\n" +
+		"| another | dev/another | added 2.0.0 |  |\n" +
+		"| myapp | dev/myapp | 1.0.0 → 1.2.0 | [source](https://github.com/myorg/myapp) [release notes](https://github.com/myorg/myapp/releases/tag/v1.2.0) |\n"
+	assert.Equal(t, expected, changelog.Markdown(changes))
+	assert.Equal(t, "", changelog.Markdown(nil))
+
+	assert.Equal(t, "title\n\nbody", changelog.JoinSections(" title\n", "", "body"))
+}
+
+func TestVersionTag(t *testing.T) {
+	testCases := map[string]string{
+		"v1.2.3":       "v1.2.3",
+		"1.2.3":        "1.2.3",
+		"1.2.3-beta.1": "1.2.3-beta.1",
+		">=1.0.0":      "",
+		"^1.2.0":       "",
+		"1.x":          "",
+		"main":         "",
+		"HEAD":         "",
+		"":             "",
+	}
+	for version, expected := range testCases {
+		assert.Equal(t, expected, changelog.VersionTag(version), "for %s", version)
+	}
+}
+
+func TestResolveTag(t *testing.T) {
+	dir := t.TempDir()
+	commands := [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"tag", "v1.2.0"},
+		{"tag", "2.0.0"},
+		{"tag", "v2.0.0"},
+	}
+	for _, args := range commands {
+		_, err := cmdrunner.QuietCommandRunner(&cmdrunner.Command{Dir: dir, Name: "git", Args: args})
+		require.NoError(t, err, "failed to run git %v", args)
+	}
+
+	testCases := map[string]string{
+		"1.2.0":  "v1.2.0",
+		"v1.2.0": "v1.2.0",
+		"2.0.0":  "2.0.0",
+		"3.0.0":  "",
+		">=1.0":  "",
+	}
+	for version, expected := range testCases {
+		tag, err := changelog.ResolveTag(cmdrunner.QuietCommandRunner, dir, version)
+		require.NoError(t, err, "failed to resolve tag for %s", version)
+		assert.Equal(t, expected, tag, "tag for version %s", version)
+	}
+}
@@ -11,24 +11,78 @@ import (
 )
 
 func (o *Options) ModifyApplicationFiles(dir, repoURL, version string) error {
+	selector, err := o.ElementSelector()
+	if err != nil {
+		return err
+	}
+
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		text := strings.TrimSpace(argocd.GetRepoURL(node, path))
-		if gitops.TrimGitURLSuffix(repoURL) != gitops.TrimGitURLSuffix(text) {
+		sources := matchingSources(node, path, repoURL, o.Chart)
+		if len(sources) == 0 {
 			return false, nil
 		}
-		kind := kyamls.GetKind(node, path)
-		var err error
-		switch kind {
-		case "Application":
-			err = argocd.SetAppVersion(node, path, version)
-		case "ApplicationSet":
-			err = argocd.SetAppSetVersion(node, path, version)
+		if o.ElementField != "" {
+			// only ApplicationSet elements are promoted so Applications can't bypass the element selectors
+			if kyamls.GetKind(node, path) != "ApplicationSet" {
+				return false, nil
+			}
+			count, err := argocd.SetAppSetElementField(node, path, o.ElementField, selector, version)
+			return count > 0, err
 		}
-		if err != nil {
-			return false, err
+		for _, source := range sources {
+			err := o.setSourceVersion(source, path, version)
+			if err != nil {
+				return false, err
+			}
 		}
 		return true, nil
 	}
 
-	return kyamls.ModifyFiles(dir, modifyFn, argocd.ApplicationFilter)
+	return o.PathFilter.ModifyFiles(dir, modifyFn, argocd.ApplicationFilter)
+}
+
+// setSourceVersion sets the version in the helm parameter, helm value or kustomize image if specified
+// otherwise the targetRevision
+func (o *Options) setSourceVersion(source *yaml.RNode, path, version string) error {
+	switch {
+	case o.HelmParameter != "":
+		return argocd.SetHelmParameter(source, path, o.HelmParameter, version)
+	case o.HelmValue != "":
+		return argocd.SetHelmValue(source, path, o.HelmValue, version)
+	case o.KustomizeImage != "":
+		return argocd.SetKustomizeImage(source, path, o.KustomizeImage, version)
+	default:
+		return argocd.SetSourceVersion(source, path, version)
+	}
+}
+
+// matchingSources returns the sources with the given repository URL and chart if specified. If the Application has a
+// source repository annotation matching the URL then its first source is used
+func matchingSources(node *yaml.RNode, path, repoURL, chart string) []*yaml.RNode {
+	repoURL = gitops.TrimGitURLSuffix(repoURL)
+
+	var answer []*yaml.RNode
+	var first *yaml.RNode
+	for _, source := range argocd.GetSources(node, path) {
+		if argocd.IsRefSource(source, path) {
+			continue
+		}
+		if chart != "" && kyamls.GetStringField(source, path, "chart") != chart {
+			continue
+		}
+		if first == nil {
+			first = source
+		}
+		text := strings.TrimSpace(kyamls.GetStringField(source, path, "repoURL"))
+		if gitops.TrimGitURLSuffix(text) == repoURL {
+			answer = append(answer, source)
+		}
+	}
+	if len(answer) == 0 && first != nil {
+		annotation := kyamls.GetStringField(node, path, "metadata", "annotations", "gitops.jenkins-x.io/sourceRepoUrl")
+		if gitops.TrimGitURLSuffix(strings.TrimSpace(annotation)) == repoURL {
+			answer = append(answer, first)
+		}
+	}
+	return answer
 }
@@ -23,9 +23,6 @@ func TestModifyApplicationFiles(t *testing.T) {
 	dirNames, err := os.ReadDir(tmpDir)
 	assert.NoError(t, err)
 
-	repoURL := "https://github.com/myorg/myrepo.git"
-	version := "v1.2.3"
-
 	for _, d := range dirNames {
 		if !d.IsDir() {
 			continue
@@ -36,6 +33,29 @@ func TestModifyApplicationFiles(t *testing.T) {
 
 		_, o := promote.NewCmdArgoPromote()
 
+		repoURL := "https://github.com/myorg/myrepo.git"
+		version := "v1.2.3"
+		switch dir {
+		case "chart":
+			repoURL = "https://charts.myorg.io"
+			version = "1.2.3"
+			o.Chart = "my-chart"
+		case "helm-parameter":
+			version = "1.2.3"
+			o.HelmParameter = "image.tag"
+		case "helm-value":
+			version = "1.2.3"
+			o.HelmValue = "image.tag"
+		case "appset-elements":
+			o.ElementField = "revision"
+			o.ElementSelectors = []string{"env=staging"}
+		case "include-path":
+			o.PathFilter.Includes = []string{"staging-*"}
+		case "kustomize-image":
+			version = "1.2.3"
+			o.KustomizeImage = "ghcr.io/myorg/myapp"
+		}
+
 		err = o.ModifyApplicationFiles(srcDir, repoURL, version)
 		require.NoError(t, err, "failed to modify files")
 
@@ -13,6 +13,8 @@ import (
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
@@ -22,14 +24,23 @@ import (
 
 // Options the command line options
 type Options struct {
-	Version       string
-	VersionFile   string
-	VersionPrefix string
-	Dir           string
-	SourceGitURL  string
-	TargetGitURL  string
-	AutoMerge     bool
+	Version          string
+	VersionFile      string
+	VersionPrefix    string
+	Dir              string
+	SourceGitURL     string
+	Targets          gitops.PromoteTargetOptions
+	PathFilter       gitops.PathFilter
+	Chart            string
+	HelmParameter    string
+	HelmValue        string
+	KustomizeImage   string
+	ElementField     string
+	ElementSelectors []string
+	AutoMerge        bool
 	environments.EnvironmentPullRequestOptions
+
+	PromoteTargets []*gitops.PromoteTarget
 }
 
 var (
@@ -51,6 +62,24 @@ var (
 
 		# lets promote a specific version of the given spec.source.repoURL (--source-git-url)
 		jx updatebot argo promote --version v1.2.3 --source-git-url https://github.com/myorg/my-chart-repo.git --target-git-url https://github.com/myorg/my-argo-repo.git
+
+		# lets promote a new image tag via a helm parameter of the Applications annotated with the source repository
+		jx updatebot argo promote --version 1.2.3 --helm-parameter image.tag --target-git-url https://github.com/myorg/my-argo-repo.git
+
+		# lets promote a new revision to the staging elements of the list generators of the ApplicationSets
+		jx updatebot argo promote --version v1.2.3 --element-field revision --element-selector env=staging --target-git-url https://github.com/myorg/my-argo-repo.git
+
+		# lets promote a specific version of a chart in a helm repository
+		jx updatebot argo promote --version 1.2.3 --source-git-url https://charts.myorg.io --chart my-app --target-git-url https://github.com/myorg/my-argo-repo.git
+
+		# lets promote to many target repositories concurrently
+		jx updatebot argo promote --version v1.2.3 --target-git-url https://github.com/myorg/my-us-repo.git --target-git-url https://github.com/myorg/my-eu-repo.git
+
+		# lets promote to the targets in a YAML file of the form: targets: [{gitUrl: ..., labels: [...], autoMerge: true, paths: [...]}]
+		jx updatebot argo promote --version v1.2.3 --targets-file promote-targets.yaml
+
+		# lets only promote into the staging directory of a monorepo using a sparse checkout
+		jx updatebot argo promote --version v1.2.3 --target-git-url https://github.com/myorg/my-gitops-repo.git --include-path 'envs/staging/**' --sparse-checkout
 	`)
 )
 
@@ -71,7 +100,14 @@ func NewCmdArgoPromote() (*cobra.Command, *Options) {
 
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory look for the VERSION file")
 	cmd.Flags().StringVarP(&o.SourceGitURL, "source-git-url", "", "", "the source repo git URL to upgrade the version")
-	cmd.Flags().StringVarP(&o.TargetGitURL, "target-git-url", "", "", "the target git URL to create a Pull Request on")
+	cmd.Flags().StringVarP(&o.Chart, "chart", "", "", "the name of the helm chart to promote. Only sources with this chart in the source repo URL are upgraded")
+	cmd.Flags().StringVarP(&o.HelmParameter, "helm-parameter", "", "", "the name of the spec.source.helm.parameters entry to set to the version rather than changing the targetRevision. e.g. image.tag")
+	cmd.Flags().StringVarP(&o.HelmValue, "helm-value", "", "", "the dot separated path in spec.source.helm.valuesObject or spec.source.helm.values to set to the version rather than changing the targetRevision. e.g. image.tag")
+	cmd.Flags().StringVarP(&o.KustomizeImage, "kustomize-image", "", "", "the image in spec.source.kustomize.images to set the tag to the version rather than changing the targetRevision")
+	cmd.Flags().StringVarP(&o.ElementField, "element-field", "", "", "the field of the ApplicationSet list generator elements to set to the version rather than changing the template. Applications are not changed when this is specified. e.g. revision")
+	cmd.Flags().StringArrayVarP(&o.ElementSelectors, "element-selector", "", nil, "the name=value fields the ApplicationSet list generator elements must have to be promoted when using --element-field. e.g. env=staging")
+	o.Targets.AddFlags(cmd)
+	o.PathFilter.AddFlags(cmd)
 	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version number to promote. If not specified uses $VERSION or the version file")
 	cmd.Flags().StringVarP(&o.VersionFile, "version-file", "", "", "the file to load the version from if not specified directly or via a $VERSION environment variable. Defaults to VERSION in the current dir")
 	cmd.Flags().StringVarP(&o.VersionPrefix, "version-prefix", "", "v", "the prefix added to the version number that will be used in the Argo CD Application or ApplicationSet YAML if --version option is not specified and the version is defaulted from $VERSION or the VERSION file")
@@ -95,19 +131,40 @@ func (o *Options) Run() error {
 		return fmt.Errorf("failed to validate options: %w", err)
 	}
 
-	err = o.upgradeRepository(o.TargetGitURL)
-	if err != nil {
-		return fmt.Errorf("failed to create Pull Request on repository %s: %w", o.TargetGitURL, err)
-	}
-	return nil
+	_, err = gitops.CreatePullRequests(o.PromoteTargets, o.upgradeRepository)
+	return err
 }
 
 func (o *Options) Validate() error {
 	var err error
 
-	if o.TargetGitURL == "" {
+	o.PromoteTargets, err = o.Targets.Targets(o.Labels, o.AutoMerge)
+	if err != nil {
+		return fmt.Errorf("failed to load promote targets: %w", err)
+	}
+	if len(o.PromoteTargets) == 0 {
 		return options.MissingOption("target-git-url")
 	}
+	err = o.PathFilter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate paths: %w", err)
+	}
+	modes := 0
+	for _, v := range []string{o.HelmParameter, o.HelmValue, o.KustomizeImage, o.ElementField} {
+		if v != "" {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("only one of --helm-parameter, --helm-value, --kustomize-image or --element-field can be specified")
+	}
+	if len(o.ElementSelectors) > 0 && o.ElementField == "" {
+		return options.MissingOption("element-field")
+	}
+	_, err = o.ElementSelector()
+	if err != nil {
+		return fmt.Errorf("failed to parse element selectors: %w", err)
+	}
 	if o.SourceGitURL == "" {
 		o.SourceGitURL, err = gitdiscovery.FindGitURLFromDir(o.Dir, true)
 		if err != nil {
@@ -159,22 +216,33 @@ func (o *Options) Validate() error {
 	return nil
 }
 
-func (o *Options) upgradeRepository(gitURL string) error {
-	// lets clear the branch name so we create a new one each time in a loop
-	o.BranchName = ""
-
-	if o.CommitTitle == "" {
-		o.CommitTitle = "chore: upgrade pipelines"
+// ElementSelector returns the map of the element selector fields
+func (o *Options) ElementSelector() (map[string]string, error) {
+	selector := map[string]string{}
+	for _, s := range o.ElementSelectors {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return nil, options.InvalidOptionf("element-selector", s, "should be of the form name=value")
+		}
+		selector[k] = v
 	}
+	return selector, nil
+}
+
+func (o *Options) upgradeRepository(target *gitops.PromoteTarget) (*scm.PullRequest, error) {
+	// lets use a copy of the options so that each target gets its own branch, clone and paths
+	to := *o
+	to.BranchName = ""
+	to.PathFilter = o.PathFilter.WithDirs(target.Paths)
+	to.SparseCheckoutPatterns = to.PathFilter.SparseCheckoutPatterns()
 
-	o.Function = func() error {
-		dir := o.OutDir
-		return o.ModifyApplicationFiles(dir, o.SourceGitURL, o.Version)
+	if to.CommitTitle == "" {
+		to.CommitTitle = "chore: upgrade pipelines"
 	}
 
-	_, err := o.EnvironmentPullRequestOptions.Create(gitURL, "", o.Labels, o.AutoMerge)
-	if err != nil {
-		return fmt.Errorf("failed to create Pull Request on repository %s: %w", gitURL, err)
+	to.Function = func() error {
+		return to.ModifyApplicationFiles(to.OutDir, to.SourceGitURL, to.Version)
 	}
-	return nil
+
+	return to.EnvironmentPullRequestOptions.Create(target.GitURL, "", target.Labels, *target.AutoMerge)
 }
@@ -0,0 +1,43 @@
+package promote_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/argo/promote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateElementOptions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		configure func(o *promote.Options)
+		expected  string
+	}{
+		{
+			name: "element-field with another mode",
+			configure: func(o *promote.Options) {
+				o.ElementField = "spec.source.targetRevision"
+				o.HelmParameter = "image.tag"
+			},
+			expected: "only one of --helm-parameter, --helm-value, --kustomize-image or --element-field can be specified",
+		},
+		{
+			name: "element-selector without element-field",
+			configure: func(o *promote.Options) {
+				o.ElementSelectors = []string{"metadata.name=myapp"}
+			},
+			expected: "element-field",
+		},
+	}
+
+	for _, tc := range testCases {
+		_, o := promote.NewCmdArgoPromote()
+		o.Targets.GitURLs = []string{"https://github.com/myorg/myrepo.git"}
+		tc.configure(o)
+
+		err := o.Validate()
+		if assert.Error(t, err, "for %s", tc.name) {
+			assert.Contains(t, err.Error(), tc.expected, "for %s", tc.name)
+		}
+	}
+}
@@ -2,13 +2,18 @@ package sync
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/argocd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/changelog"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
 	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/input"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/input/inputfactory"
@@ -25,7 +30,12 @@ var (
 	cmdLong = templates.LongDesc(`
 		Synchronizes some or all applications in an ArgoCD git repository to reduce version drift
 
-		Creates a Pull Request on the target GitOps repository.
+		Creates a Pull Request on the target GitOps repository. Applications in the source repository which are missing in the target repository are
+		added with their destination namespace and server changed to those of the target repository unless --update-only is specified.
+
+		The Pull Request body lists the changed versions grouped by destination namespace with links to the source repository of each chart
+		when its git URL is in the version stream of the source repository. Git sources whose targetRevision is an exact version also link to
+		the release notes of that tag.
 `)
 
 	cmdExample = templates.Examples(`
@@ -36,10 +46,16 @@ var (
 		jx updatebot argo sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo
 
 		# create a Pull Request if any of the versions are out of sync including only the given repo URL strings
-		jx updatebot argo sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --repourl-includes wine  --repourl-includes beer 
+		jx updatebot argo sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --repourl-include wine --repourl-include beer
+
+		# create a Pull Request updating the versions of the applications in the target repo without adding any missing applications
+		jx updatebot argo sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --update-only
 
 		# create a Pull Request if any of the versions are out of sync excluding the given repo URL strings
-		jx updatebot argo sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --repourl-excludes water
+		jx updatebot argo sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --repourl-exclude water
+
+		# create a Pull Request only modifying the files in the production directory of the target repo using a sparse checkout
+		jx updatebot argo sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-gitops-repo --include-path 'envs/production/**' --sparse-checkout
 	`)
 )
 
@@ -50,6 +66,7 @@ type Options struct {
 
 	Source             gitops.RepositoryOptions
 	Target             gitops.RepositoryOptions
+	PathFilter         gitops.PathFilter
 	AppFilter          argocd.AppFilter
 	GitCommitUsername  string
 	GitCommitUserEmail string
@@ -63,6 +80,20 @@ type Options struct {
 	VersionStreamDir   string
 	Prefixes           *versionstream.RepositoryPrefixes
 	SourceApplications map[string]*argocd.AppVersion
+	DestinationNS      string
+	DestinationServer  string
+	Changes            []*changelog.Change
+
+	sourceManifests  map[string]*sourceManifest
+	targetApps       map[string]bool
+	targetNamespaces map[string]bool
+	targetServers    map[string]bool
+}
+
+// sourceManifest an Application in the source repository which may need to be added to the target
+type sourceManifest struct {
+	Path string
+	Node *yaml.RNode
 }
 
 // NewCmdArgoSync creates a command object for the command
@@ -86,12 +117,15 @@ func NewCmdArgoSync() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.GitCommitUserEmail, "git-user-email", "", "", "the user email to git commit")
 	cmd.Flags().StringSliceVar(&o.Labels, "labels", []string{}, "a list of labels to apply to the PR")
 	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", true, "should we automatically merge if the PR pipeline is green")
-	// TODO support adding missing releases?
-	// cmd.Flags().BoolVarP(&o.UpdateOnly, "update-only", "", false, "only update versions in the target environment/namespace - do not add any new charts that are missing")
+	cmd.Flags().BoolVarP(&o.UpdateOnly, "update-only", "", false, "only update versions in the target repository - do not add any new applications that are missing")
+	cmd.Flags().StringVarP(&o.DestinationNS, "destination-namespace", "", "", "the destination namespace of applications added to the target repository. Defaults to the destination namespace of the applications in the target repository")
+	cmd.Flags().StringVarP(&o.DestinationServer, "destination-server", "", "", "the destination server of applications added to the target repository. Defaults to the destination server of the applications in the target repository")
 	cmd.Flags().BoolVarP(&o.GitCredentials, "git-credentials", "", false, "ensures the git credentials are setup so we can push to git")
 
 	o.AppFilter.AddFlags(cmd)
 
+	o.PathFilter.AddFlags(cmd)
+
 	o.BaseOptions.AddBaseFlags(cmd)
 	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
 
@@ -113,6 +147,15 @@ func (o *Options) Validate() error {
 	if err != nil {
 		return fmt.Errorf("failed to validate base options: %w", err)
 	}
+	err = o.AppFilter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate filters: %w", err)
+	}
+	err = o.PathFilter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate paths: %w", err)
+	}
+	o.SparseCheckoutPatterns = o.PathFilter.SparseCheckoutPatterns()
 	if o.Input == nil {
 		o.Input = inputfactory.NewInput(&o.BaseOptions)
 	}
@@ -161,9 +204,13 @@ func (o *Options) Run() error {
 		o.CommitTitle = "chore: sync versions"
 	}
 
+	commitMessage := o.CommitMessage
 	o.Function = func() error {
 		dir := o.OutDir
-		return o.SyncVersions(o.Source.Dir, dir)
+		o.Changes = nil
+		err := o.SyncVersions(o.Source.Dir, dir)
+		o.CommitMessage = changelog.JoinSections(commitMessage, changelog.Markdown(o.Changes))
+		return err
 	}
 
 	_, err = o.EnvironmentPullRequestOptions.Create(gitURL, "", o.Labels, o.AutoMerge)
@@ -175,6 +222,17 @@ func (o *Options) Run() error {
 
 // SyncVersions syncs the source and target versions
 func (o *Options) SyncVersions(sourceDir, targetDir string) error {
+	if o.VersionStreamDir == "" {
+		o.VersionStreamDir = filepath.Join(sourceDir, "versionStream")
+	}
+	if o.Prefixes == nil {
+		var err error
+		o.Prefixes, err = versionstream.GetRepositoryPrefixes(o.VersionStreamDir)
+		if err != nil {
+			return fmt.Errorf("failed to load repository prefixes from version stream dir %s: %w", o.VersionStreamDir, err)
+		}
+	}
+
 	err := o.findSourceApplications(sourceDir)
 	if err != nil {
 		return fmt.Errorf("failed to find source Applications: %w", err)
@@ -184,6 +242,14 @@ func (o *Options) SyncVersions(sourceDir, targetDir string) error {
 	if err != nil {
 		return fmt.Errorf("failed to modify target Applications: %w", err)
 	}
+
+	if o.UpdateOnly {
+		return nil
+	}
+	err = o.addMissingApplications(targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to add missing Applications: %w", err)
+	}
 	return nil
 }
 
@@ -191,38 +257,185 @@ func (o *Options) findSourceApplications(dir string) error {
 	if o.SourceApplications == nil {
 		o.SourceApplications = map[string]*argocd.AppVersion{}
 	}
+	o.sourceManifests = map[string]*sourceManifest{}
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		v := argocd.GetAppVersion(node, path)
-		if v.RepoURL == "" || v.Version == "" {
-			return false, nil
+		if kyamls.GetKind(node, path) == "Application" {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return false, fmt.Errorf("failed to get relative path of %s: %w", path, err)
+			}
+			o.sourceManifests[kyamls.GetName(node, path)] = &sourceManifest{Path: rel, Node: node}
 		}
 
-		log.Logger().Debugf("found source %s", v.String())
+		for _, v := range argocd.GetAppVersions(node, path) {
+			if v.RepoURL == "" || v.Version == "" {
+				continue
+			}
+
+			log.Logger().Debugf("found source %s", v.String())
 
-		k := v.Key()
-		o.SourceApplications[k] = v
+			k := v.Key()
+			o.SourceApplications[k] = v
+		}
 		return false, nil
 	}
 	return kyamls.ModifyFiles(dir, modifyFn, argocd.ApplicationFilter)
 }
 
 func (o *Options) syncAppVersions(dir string) error {
+	o.targetApps = map[string]bool{}
+	o.targetNamespaces = map[string]bool{}
+	o.targetServers = map[string]bool{}
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		v := argocd.GetAppVersion(node, path)
-		if v.RepoURL == "" || !o.AppFilter.Matches(v) {
-			return false, nil
+		if kyamls.GetKind(node, path) == "Application" {
+			o.targetApps[kyamls.GetName(node, path)] = true
+			o.targetNamespaces[kyamls.GetStringField(node, path, "spec", "destination", "namespace")] = true
+			o.targetServers[kyamls.GetStringField(node, path, "spec", "destination", "server")] = true
+		}
+
+		modified := false
+		for _, sourceNode := range argocd.GetSources(node, path) {
+			if argocd.IsRefSource(sourceNode, path) {
+				continue
+			}
+			v := argocd.GetAppSourceVersion(node, sourceNode, path)
+			if v.RepoURL == "" || !o.AppFilter.Matches(v) {
+				continue
+			}
+			k := v.Key()
+			source := o.SourceApplications[k]
+			if source == nil || source.Version == v.Version {
+				continue
+			}
+
+			err := argocd.SetSourceVersion(sourceNode, path, source.Version)
+			if err != nil {
+				return false, err
+			}
+			o.addChange(kyamls.GetName(node, path), v, v.Version, source.Version)
+			modified = true
+		}
+		return modified, nil
+	}
+	return o.PathFilter.ModifyFiles(dir, modifyFn, argocd.ApplicationFilter)
+}
+
+// addMissingApplications adds the source Applications which are not in the target repository
+func (o *Options) addMissingApplications(dir string) error {
+	namespace := o.DestinationNS
+	if namespace == "" {
+		namespace = singleValue(o.targetNamespaces)
+	}
+	server := o.DestinationServer
+	if server == "" {
+		server = singleValue(o.targetServers)
+	}
+
+	var names []string
+	for name := range o.sourceManifests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if o.targetApps[name] {
+			continue
+		}
+		m := o.sourceManifests[name]
+		if !o.PathFilter.Matches(m.Path) || !o.matchesAppFilter(m.Node, m.Path) {
+			continue
+		}
+
+		path := filepath.Join(dir, m.Path)
+		exists, err := files.FileExists(path)
+		if err != nil {
+			return fmt.Errorf("failed to check if file exists %s: %w", path, err)
 		}
-		k := v.Key()
-		source := o.SourceApplications[k]
-		if source == nil {
-			return false, nil
+		if exists {
+			log.Logger().Warnf("cannot add Application %s as the file %s already exists in the target repository", name, m.Path)
+			continue
 		}
 
-		err := argocd.SetAppVersion(node, path, source.Version)
+		node := m.Node.Copy()
+		if namespace != "" {
+			err = node.PipeE(yaml.LookupCreate(yaml.ScalarNode, "spec", "destination", "namespace"), yaml.FieldSetter{StringValue: namespace})
+			if err != nil {
+				return fmt.Errorf("failed to set the destination namespace of Application %s: %w", name, err)
+			}
+		}
+		if server != "" {
+			err = node.PipeE(yaml.LookupCreate(yaml.ScalarNode, "spec", "destination", "server"), yaml.FieldSetter{StringValue: server})
+			if err != nil {
+				return fmt.Errorf("failed to set the destination server of Application %s: %w", name, err)
+			}
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+		if err != nil {
+			return fmt.Errorf("failed to create dir for %s: %w", path, err)
+		}
+		err = yaml.WriteFile(node, path)
 		if err != nil {
-			return false, err
+			return fmt.Errorf("failed to save %s: %w", path, err)
+		}
+		log.Logger().Infof("added Application %s to %s", name, m.Path)
+		for _, v := range argocd.GetAppVersions(node, path) {
+			if v.Version != "" {
+				o.addChange(name, v, "", v.Version)
+			}
 		}
-		return true, nil
 	}
-	return kyamls.ModifyFiles(dir, modifyFn, argocd.ApplicationFilter)
+	return nil
+}
+
+// addChange records the change of the application source version for the Pull Request body. The targetRevision of
+// a git source is the git tag if it is an exact version
+func (o *Options) addChange(name string, v *argocd.AppVersion, from, to string) {
+	gitURL := v.RepoURL
+	tag := changelog.VersionTag(to)
+	if v.Chart != "" {
+		gitURL = ""
+		tag = ""
+		if prefix := o.Prefixes.PrefixForURL(v.RepoURL); prefix != "" {
+			gitURL = changelog.ChartGitURL(o.VersionStreamDir, prefix+"/"+v.Chart)
+		}
+	}
+	chart := v.Chart
+	if chart == "" {
+		chart = v.Path
+	}
+	o.Changes = append(o.Changes, &changelog.Change{
+		Namespace: v.DestinationNamespace,
+		Name:      name,
+		Chart:     chart,
+		From:      from,
+		To:        to,
+		GitURL:    gitURL,
+		Tag:       tag,
+	})
+}
+
+// matchesAppFilter returns true if any of the sources of the Application match the filter
+func (o *Options) matchesAppFilter(node *yaml.RNode, path string) bool {
+	for _, v := range argocd.GetAppVersions(node, path) {
+		if o.AppFilter.Matches(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// singleValue returns the value if there is only one non-blank value in the set
+func singleValue(values map[string]bool) string {
+	answer := ""
+	for v := range values {
+		if v == "" {
+			continue
+		}
+		if answer != "" {
+			return ""
+		}
+		answer = v
+	}
+	return answer
 }
@@ -7,9 +7,11 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/changelog"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/argo/sync"
 
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -49,6 +51,8 @@ func TestArgoSync(t *testing.T) {
 			o.AppFilter.RepoURL.Includes = []string{"app1"}
 		case "exclude-app1":
 			o.AppFilter.RepoURL.Excludes = []string{"app1"}
+		case "update-only":
+			o.UpdateOnly = true
 		}
 
 		srcDir := filepath.Join(dir, "source")
@@ -60,6 +64,11 @@ func TestArgoSync(t *testing.T) {
 
 		err = o.SyncVersions(srcDir, targetDir)
 		require.NoError(t, err, "failed to run sync command")
+		if name == "simple" {
+			body := changelog.Markdown(o.Changes)
+			assert.Contains(t, body, "| app1 | charts/app1 | v0.0.51 → v0.0.52 | [source](https://github.com/myorg/app1) [release notes](https://github.com/myorg/app1/releases/tag/v0.0.52) |", "body %s", body)
+			assert.Contains(t, body, "| app2 | charts/app2 | v1.2.1 → v1.2.3 |", "body %s", body)
+		}
 
 		AssertDirContentsEqual(t, generateTestOutput, verbose, targetDir, expectedDir)
 	}
@@ -109,4 +118,18 @@ func AssertDirContentsEqual(t *testing.T, generateTestOutput, verbose bool, dir,
 		return nil
 	})
 	require.NoError(t, err, "failed to walk the source dir")
+
+	if generateTestOutput {
+		return
+	}
+	err = filepath.Walk(expectedDir, func(path string, info os.FileInfo, err error) error { //nolint:staticcheck
+		if info == nil || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(expectedDir, path) //nolint:staticcheck
+		require.NoError(t, err, "failed to return relative path")
+		require.FileExists(t, filepath.Join(dir, rel), "expected file %s should have been created", rel)
+		return nil
+	})
+	require.NoError(t, err, "failed to walk the expected dir")
 }
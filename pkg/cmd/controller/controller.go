@@ -0,0 +1,422 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/client/clientset/versioned"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/client/informers/externalversions"
+	listers "github.com/jenkins-x-plugins/jx-updatebot/pkg/client/listers/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/updatebotclient"
+	jxv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	jxc "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned"
+	jxinformers "github.com/jenkins-x/jx-api/v4/pkg/client/informers/externalversions"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Runs a controller which watches for releases and creates Pull Requests on the downstream repositories
+
+		The controller watches Jenkins X Release and PipelineActivity resources and whenever a new release of a
+		repository appears it runs the rules of each UpdateConfig resource which lists that repository in its sourceURLs.
+
+		Processed resources are annotated so that on startup the controller only processes the releases created
+		within the --max-release-age which have not already been processed.
+`)
+
+	cmdExample = templates.Examples(`
+		# run the controller in the current namespace
+		jx updatebot controller --git-server https://github.com
+
+		# run the controller without leader election
+		jx updatebot controller --git-server https://github.com --leader-elect=false
+`)
+)
+
+const (
+	// ProcessedAnnotation the annotation added to the Release and PipelineActivity resources once processed
+	ProcessedAnnotation = "updatebot.jenkins-x.io/processed"
+
+	// processedCacheSize the maximum number of releases remembered to avoid processing a release twice
+	processedCacheSize = 1000
+
+	// processedTTL how long a processed release is remembered
+	processedTTL = 24 * time.Hour
+)
+
+// Options the options for the command
+type Options struct {
+	Namespace        string
+	LeaseName        string
+	ResyncPeriod     time.Duration
+	Workers          int
+	MaxRetries       int
+	MaxReleaseAge    time.Duration
+	AutoMerge        bool
+	LeaderElection   bool
+	KubeClient       kubernetes.Interface
+	JXClient         jxc.Interface
+	UpdatebotClient  versioned.Interface
+	ScmClientFactory scmhelpers.Factory
+	CommandRunner    cmdrunner.CommandRunner
+
+	// Trigger is invoked for each UpdateConfig which matches a new release. Defaults to creating the Pull Requests
+	Trigger func(config *v1alpha1.UpdateConfig, event ReleaseEvent) error
+
+	queue           workqueue.TypedRateLimitingInterface[queueItem]
+	jxInformers     jxinformers.SharedInformerFactory
+	configInformers externalversions.SharedInformerFactory
+	configLister    listers.UpdateConfigLister
+	processed       *utilcache.LRUExpireCache
+	lock            sync.Mutex
+}
+
+// queueItem a release event and the resource it was found in
+type queueItem struct {
+	ReleaseEvent
+	Kind string
+	Name string
+}
+
+// NewCmdController creates a command object for the command
+func NewCmdController() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "controller",
+		Short:   "Runs a controller which watches for releases and creates Pull Requests on the downstream repositories",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "the namespace to watch. Defaults to the current namespace")
+	cmd.Flags().StringVarP(&o.LeaseName, "lease-name", "", "jx-updatebot", "the name of the Lease used for leader election")
+	cmd.Flags().DurationVarP(&o.ResyncPeriod, "resync-period", "", 10*time.Minute, "the resync period of the informers")
+	cmd.Flags().IntVarP(&o.Workers, "workers", "", 1, "the number of workers processing releases")
+	cmd.Flags().IntVarP(&o.MaxRetries, "max-retries", "", 5, "the maximum number of times to retry a failed release")
+	cmd.Flags().DurationVarP(&o.MaxReleaseAge, "max-release-age", "", time.Hour, "the maximum age of the existing releases which are processed on startup if they have not already been processed")
+	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", true, "should we automatically merge if the PR pipeline is green")
+	cmd.Flags().BoolVarP(&o.LeaderElection, "leader-elect", "", true, "enables leader election so that only one replica creates Pull Requests")
+	o.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if !o.LeaderElection {
+		return o.Start(ctx)
+	}
+	return o.runWithLeaderElection(ctx)
+}
+
+// Validate lazily creates the clients, work queue and informers
+func (o *Options) Validate() error {
+	var err error
+	o.JXClient, o.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.JXClient, o.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create jx client: %w", err)
+	}
+	o.UpdatebotClient, o.Namespace, err = updatebotclient.LazyCreateUpdatebotClientAndNamespace(o.UpdatebotClient, o.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create updatebot client: %w", err)
+	}
+	if o.LeaderElection {
+		o.KubeClient, err = kube.LazyCreateKubeClient(o.KubeClient)
+		if err != nil {
+			return fmt.Errorf("failed to create kube client: %w", err)
+		}
+	}
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.DefaultCommandRunner
+	}
+	if o.Trigger == nil {
+		// lets resolve the git server and token once rather than for each release
+		if o.ScmClientFactory.GitServerURL == "" {
+			return options.MissingOption("git-server")
+		}
+		_, err = o.ScmClientFactory.Create()
+		if err != nil {
+			return fmt.Errorf("failed to create ScmClient: %w", err)
+		}
+		o.Trigger = o.CreatePullRequests
+	}
+	if o.Workers <= 0 {
+		o.Workers = 1
+	}
+	o.processed = utilcache.NewLRUExpireCache(processedCacheSize)
+	o.queue = workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.DefaultTypedControllerRateLimiter[queueItem](),
+		workqueue.TypedRateLimitingQueueConfig[queueItem]{Name: "jx-updatebot"},
+	)
+
+	o.configInformers = externalversions.NewSharedInformerFactoryWithOptions(o.UpdatebotClient, o.ResyncPeriod, externalversions.WithNamespace(o.Namespace))
+	configInformer := o.configInformers.Updatebot().V1alpha1().UpdateConfigs()
+	o.configLister = configInformer.Lister()
+
+	o.jxInformers = jxinformers.NewSharedInformerFactoryWithOptions(o.JXClient, o.ResyncPeriod, jxinformers.WithNamespace(o.Namespace))
+	_, err = o.jxInformers.Jenkins().V1().Releases().Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			r, ok := obj.(*jxv1.Release)
+			if ok && o.isUnprocessed(&r.ObjectMeta, isInInitialList) {
+				o.enqueue(ReleaseEventFromRelease(r), "Release", r.Name)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch Release resources: %w", err)
+	}
+	_, err = o.jxInformers.Jenkins().V1().PipelineActivities().Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			pa, ok := obj.(*jxv1.PipelineActivity)
+			if ok && o.isUnprocessed(&pa.ObjectMeta, isInInitialList) {
+				o.enqueue(ReleaseEventFromPipelineActivity(pa), "PipelineActivity", pa.Name)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPA, ok := oldObj.(*jxv1.PipelineActivity)
+			if !ok || oldPA.Spec.Status == jxv1.ActivityStatusTypeSucceeded {
+				return
+			}
+			if pa, ok := newObj.(*jxv1.PipelineActivity); ok {
+				o.enqueue(ReleaseEventFromPipelineActivity(pa), "PipelineActivity", pa.Name)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch PipelineActivity resources: %w", err)
+	}
+	return nil
+}
+
+// Start starts the informers and processes releases until the context is done
+func (o *Options) Start(ctx context.Context) error {
+	err := o.StartInformers(ctx)
+	if err != nil {
+		o.queue.ShutDown()
+		return err
+	}
+	o.ProcessReleases(ctx)
+	return nil
+}
+
+// StartInformers starts the informers and waits for their caches to sync
+func (o *Options) StartInformers(ctx context.Context) error {
+	log.Logger().Infof("watching for releases in namespace %s", info(o.Namespace))
+
+	o.configInformers.Start(ctx.Done())
+	o.jxInformers.Start(ctx.Done())
+	for t, ok := range o.configInformers.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return fmt.Errorf("failed to sync the informer cache for %v", t)
+		}
+	}
+	for t, ok := range o.jxInformers.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			return fmt.Errorf("failed to sync the informer cache for %v", t)
+		}
+	}
+	return nil
+}
+
+// ProcessReleases processes the queued releases until the context is done
+func (o *Options) ProcessReleases(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	for i := 0; i < o.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o.processNextItem() {
+			}
+		}()
+	}
+	<-ctx.Done()
+	o.queue.ShutDown()
+	wg.Wait()
+}
+
+func (o *Options) runWithLeaderElection(ctx context.Context) error {
+	id, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, o.Namespace, o.LeaseName,
+		o.KubeClient.CoreV1(), o.KubeClient.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: id})
+	if err != nil {
+		return fmt.Errorf("failed to create leader election lock: %w", err)
+	}
+
+	var startErr error
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            o.LeaseName,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Logger().Infof("%s is now the leader", info(id))
+				startErr = o.Start(ctx)
+			},
+			OnStoppedLeading: func() {
+				log.Logger().Infof("%s is no longer the leader", info(id))
+			},
+		},
+	})
+	return startErr
+}
+
+// isUnprocessed returns true if the resource has not been processed. Resources in the initial list of the informers
+// are only processed if they were created within the --max-release-age
+func (o *Options) isUnprocessed(m *metav1.ObjectMeta, isInInitialList bool) bool {
+	if m.Annotations[ProcessedAnnotation] != "" {
+		return false
+	}
+	return !isInInitialList || time.Since(m.CreationTimestamp.Time) <= o.MaxReleaseAge
+}
+
+func (o *Options) enqueue(event *ReleaseEvent, kind, name string) {
+	if event == nil {
+		return
+	}
+	log.Logger().Debugf("queuing release %s of %s from %s %s", event.Version, event.GitURL, kind, name)
+	o.queue.Add(queueItem{ReleaseEvent: *event, Kind: kind, Name: name})
+}
+
+func (o *Options) processNextItem() bool {
+	item, shutdown := o.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer o.queue.Done(item)
+
+	event := item.ReleaseEvent
+	err := o.SyncRelease(event)
+	if err == nil {
+		err = o.markProcessed(item)
+		if err != nil {
+			log.Logger().Warnf("failed to mark %s %s as processed: %s", item.Kind, item.Name, err.Error())
+		}
+		o.queue.Forget(item)
+		return true
+	}
+	if o.queue.NumRequeues(item) < o.MaxRetries {
+		log.Logger().Warnf("failed to process release %s of %s, will retry: %s", event.Version, event.GitURL, err.Error())
+		o.queue.AddRateLimited(item)
+		return true
+	}
+	log.Logger().Errorf("failed to process release %s of %s: %s", event.Version, event.GitURL, err.Error())
+	o.queue.Forget(item)
+	return true
+}
+
+// markProcessed annotates the resource of the release so that it is not processed again after a restart
+func (o *Options) markProcessed(item queueItem) error {
+	ctx := context.TODO()
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:"true"}}}`, ProcessedAnnotation))
+	var err error
+	switch item.Kind {
+	case "Release":
+		_, err = o.JXClient.JenkinsV1().Releases(o.Namespace).Patch(ctx, item.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "PipelineActivity":
+		_, err = o.JXClient.JenkinsV1().PipelineActivities(o.Namespace).Patch(ctx, item.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	return err
+}
+
+// SyncRelease triggers each UpdateConfig which matches the release if it has not already been processed
+func (o *Options) SyncRelease(event ReleaseEvent) error {
+	o.lock.Lock()
+	_, done := o.processed.Get(event)
+	o.lock.Unlock()
+	if done {
+		return nil
+	}
+
+	configs, err := o.configLister.UpdateConfigs(o.Namespace).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list UpdateConfig resources in namespace %s: %w", o.Namespace, err)
+	}
+	var errs []string
+	for _, config := range configs {
+		if !pr.MatchesSourceURL(config, event.GitURL) {
+			continue
+		}
+		log.Logger().Infof("release %s of %s triggered UpdateConfig %s", info(event.Version), info(event.GitURL), info(config.Name))
+		err = o.Trigger(config, event)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("UpdateConfig %s: %s", config.Name, err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to create Pull Requests for %s", strings.Join(errs, ", "))
+	}
+
+	o.lock.Lock()
+	o.processed.Add(event, true, processedTTL)
+	o.lock.Unlock()
+	return nil
+}
+
+// CreatePullRequests runs the rules of the given UpdateConfig for the release
+func (o *Options) CreatePullRequests(config *v1alpha1.UpdateConfig, event ReleaseEvent) error {
+	dir, err := os.MkdirTemp("", "jx-updatebot-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, po := pr.NewCmdPullRequest()
+	po.Dir = dir
+	po.Version = event.Version
+	po.ConfigResource = config.Name
+	po.ConfigNamespace = config.Namespace
+	po.UpdatebotClient = o.UpdatebotClient
+	po.AutoMerge = o.AutoMerge
+	po.Application = event.Application()
+	po.CommitMessage = fmt.Sprintf("from: %s\n", event.GitURL)
+	po.PipelineRepoURL = event.GitURL
+	po.CommandRunner = o.CommandRunner
+	po.ScmClientFactory = o.ScmClientFactory
+	return po.Run()
+}
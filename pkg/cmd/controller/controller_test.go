@@ -0,0 +1,218 @@
+package controller_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/controller"
+	jxv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	jxfake "github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestController(t *testing.T) {
+	// the jx fake client does not support the WatchList semantics of the informers
+	t.Setenv("KUBE_FEATURE_WatchListClient", "false")
+
+	ns := "jx"
+	config := &v1alpha1.UpdateConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mylib",
+			Namespace: ns,
+		},
+		Spec: v1alpha1.UpdateConfigSpec{
+			SourceURLs: []string{"https://github.com/myorg/mylib.git"},
+		},
+	}
+	otherConfig := &v1alpha1.UpdateConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other",
+			Namespace: ns,
+		},
+		Spec: v1alpha1.UpdateConfigSpec{
+			SourceURLs: []string{"https://github.com/myorg/other"},
+		},
+	}
+	oldRelease := &jxv1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mylib-v1.0.0",
+			Namespace: ns,
+		},
+		Spec: jxv1.ReleaseSpec{
+			GitHTTPURL: "https://github.com/myorg/mylib",
+			Version:    "v1.0.0",
+		},
+	}
+
+	// a release created just before the controller started which should be processed
+	missedRelease := &jxv1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "mylib-v1.0.1",
+			Namespace:         ns,
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: jxv1.ReleaseSpec{
+			GitHTTPURL: "https://github.com/myorg/mylib",
+			Version:    "v1.0.1",
+		},
+	}
+	processedRelease := &jxv1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "mylib-v1.0.2",
+			Namespace:         ns,
+			CreationTimestamp: metav1.Now(),
+			Annotations: map[string]string{
+				controller.ProcessedAnnotation: "true",
+			},
+		},
+		Spec: jxv1.ReleaseSpec{
+			GitHTTPURL: "https://github.com/myorg/mylib",
+			Version:    "v1.0.2",
+		},
+	}
+
+	jxClient := jxfake.NewSimpleClientset(oldRelease, missedRelease, processedRelease)
+
+	var lock sync.Mutex
+	var triggered []string
+	_, o := controller.NewCmdController()
+	o.Namespace = ns
+	o.LeaderElection = false
+	o.JXClient = jxClient
+	o.UpdatebotClient = fake.NewSimpleClientset(config, otherConfig)
+	o.Trigger = func(config *v1alpha1.UpdateConfig, event controller.ReleaseEvent) error {
+		lock.Lock()
+		defer lock.Unlock()
+		triggered = append(triggered, config.Name+":"+event.Version)
+		return nil
+	}
+	err := o.Validate()
+	require.NoError(t, err, "failed to validate")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = o.StartInformers(ctx)
+	require.NoError(t, err, "failed to start informers")
+
+	done := make(chan struct{})
+	go func() {
+		o.ProcessReleases(ctx)
+		close(done)
+	}()
+
+	pa := &jxv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myorg-mylib-master-5",
+			Namespace: ns,
+		},
+		Spec: jxv1.PipelineActivitySpec{
+			Pipeline:  "myorg/mylib/master",
+			GitURL:    "https://github.com/myorg/mylib.git",
+			GitBranch: "master",
+			Version:   "1.1.0",
+			Status:    jxv1.ActivityStatusTypeRunning,
+		},
+	}
+	pa, err = jxClient.JenkinsV1().PipelineActivities(ns).Create(ctx, pa, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create PipelineActivity")
+
+	pa.Spec.Status = jxv1.ActivityStatusTypeSucceeded
+	_, err = jxClient.JenkinsV1().PipelineActivities(ns).Update(ctx, pa, metav1.UpdateOptions{})
+	require.NoError(t, err, "failed to update PipelineActivity")
+
+	release := &jxv1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mylib-v1.1.0",
+			Namespace: ns,
+		},
+		Spec: jxv1.ReleaseSpec{
+			GitHTTPURL: "https://github.com/myorg/mylib",
+			Version:    "v1.1.0",
+		},
+	}
+	_, err = jxClient.JenkinsV1().Releases(ns).Create(ctx, release, metav1.CreateOptions{})
+	require.NoError(t, err, "failed to create Release")
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(triggered) > 1
+	}, 5*time.Second, 50*time.Millisecond, "should have triggered the UpdateConfig")
+
+	// lets give any duplicate events a chance to be processed
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+	<-done
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.ElementsMatch(t, []string{"mylib:1.0.1", "mylib:1.1.0"}, triggered, "should only trigger the matching UpdateConfig once for the unprocessed releases")
+
+	for _, name := range []string{"mylib-v1.0.1", "mylib-v1.1.0"} {
+		r, err := jxClient.JenkinsV1().Releases(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		require.NoError(t, err, "failed to get Release %s", name)
+		assert.Equal(t, "true", r.Annotations[controller.ProcessedAnnotation], "should have marked Release %s as processed", name)
+	}
+}
+
+func TestControllerValidateRequiresGitServer(t *testing.T) {
+	_, o := controller.NewCmdController()
+	o.Namespace = "jx"
+	o.LeaderElection = false
+	o.JXClient = jxfake.NewSimpleClientset()
+	o.UpdatebotClient = fake.NewSimpleClientset()
+	err := o.Validate()
+	require.Error(t, err, "should require the git server")
+	assert.Contains(t, err.Error(), "git-server")
+}
+
+func TestReleaseEventFromPipelineActivity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     jxv1.PipelineActivitySpec
+		expected *controller.ReleaseEvent
+	}{
+		{
+			name: "release",
+			spec: jxv1.PipelineActivitySpec{
+				GitURL:    "https://github.com/myorg/mylib.git",
+				GitBranch: "main",
+				Version:   "v2.0.1",
+				Status:    jxv1.ActivityStatusTypeSucceeded,
+			},
+			expected: &controller.ReleaseEvent{
+				GitURL:  "https://github.com/myorg/mylib",
+				Version: "2.0.1",
+			},
+		},
+		{
+			name: "running",
+			spec: jxv1.PipelineActivitySpec{
+				GitURL:    "https://github.com/myorg/mylib.git",
+				GitBranch: "main",
+				Version:   "2.0.1",
+				Status:    jxv1.ActivityStatusTypeRunning,
+			},
+		},
+		{
+			name: "pull-request",
+			spec: jxv1.PipelineActivitySpec{
+				GitURL:    "https://github.com/myorg/mylib.git",
+				GitBranch: "PR-12",
+				Version:   "0.0.0-SNAPSHOT-PR-12-1",
+				Status:    jxv1.ActivityStatusTypeSucceeded,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		pa := &jxv1.PipelineActivity{Spec: tc.spec}
+		event := controller.ReleaseEventFromPipelineActivity(pa)
+		assert.Equal(t, tc.expected, event, "for test %s", tc.name)
+	}
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	jxv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+)
+
+// ReleaseEvent a new release of an upstream repository. Releases are found from both Release and PipelineActivity
+// resources so the git URL is normalised to avoid processing the same release twice
+type ReleaseEvent struct {
+	GitURL  string
+	Version string
+}
+
+// Application returns the owner/name of the released repository
+func (e *ReleaseEvent) Application() string {
+	parts := strings.Split(gitops.TrimGitURLSuffix(e.GitURL), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// ReleaseEventFromRelease returns the release event for the given Release or nil if it has no git URL or version
+func ReleaseEventFromRelease(r *jxv1.Release) *ReleaseEvent {
+	gitURL := r.Spec.GitHTTPURL
+	if gitURL == "" {
+		gitURL = r.Spec.GitCloneURL
+	}
+	if gitURL == "" || r.Spec.Version == "" {
+		return nil
+	}
+	return &ReleaseEvent{
+		GitURL:  gitops.TrimGitURLSuffix(gitURL),
+		Version: strings.TrimPrefix(r.Spec.Version, "v"),
+	}
+}
+
+// ReleaseEventFromPipelineActivity returns the release event for a successful release pipeline
+// or nil if the pipeline is not a successful release
+func ReleaseEventFromPipelineActivity(pa *jxv1.PipelineActivity) *ReleaseEvent {
+	if pa.Spec.Status != jxv1.ActivityStatusTypeSucceeded || pa.Spec.GitURL == "" || pa.Spec.Version == "" {
+		return nil
+	}
+	branch := pa.Spec.GitBranch
+	if branch == "" {
+		paths := strings.Split(pa.Spec.Pipeline, "/")
+		branch = paths[len(paths)-1]
+	}
+	if branch != "master" && branch != "main" {
+		return nil
+	}
+	return &ReleaseEvent{
+		GitURL:  gitops.TrimGitURLSuffix(pa.Spec.GitURL),
+		Version: strings.TrimPrefix(pa.Spec.Version, "v"),
+	}
+}
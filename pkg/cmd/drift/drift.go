@@ -0,0 +1,281 @@
+package drift
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/drift"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-api/v4/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// KindHelmfile compares helmfile releases
+	KindHelmfile = "helmfile"
+
+	// KindArgo compares ArgoCD Applications
+	KindArgo = "argo"
+
+	// KindFlux compares Flux HelmReleases and sources
+	KindFlux = "flux"
+)
+
+// Kinds the kinds of git repository which can be compared
+var Kinds = []string{KindHelmfile, KindArgo, KindFlux}
+
+var (
+	cmdLong = templates.LongDesc(`
+		Reports the version drift between the charts or applications of two environments or git repositories without creating a Pull Request
+
+		The report shows the source version, the target version and how far behind the target is (major, minor or patch).
+		Use --fail-on to return a non zero exit code if any version is too far behind so that the report can gate a CI pipeline.
+		Flux HelmReleases are matched by their name, namespace and target namespace. Use --chart-fallback to match HelmReleases
+		which use different namespaces in each repository by their chart and sourceRef name.
+`)
+
+	cmdExample = templates.Examples(`
+		# report the drift between the helmfile releases of 2 environments
+		jx updatebot drift --source-env staging --target-env production
+
+		# report the drift between the ArgoCD Applications of 2 git repositories as markdown
+		jx updatebot drift --kind argo --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo -o markdown
+
+		# fail if any Flux HelmRelease in the target repository is a minor version or more behind the current directory
+		jx updatebot drift --kind flux --target-git-url https://github.com/myorg/my-production-repo --fail-on minor
+
+		# report the drift between Flux HelmReleases which use different namespaces in each repository
+		jx updatebot drift --kind flux --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --chart-fallback
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Kind            string
+	Source          gitops.RepositoryOptions
+	Target          gitops.RepositoryOptions
+	SourceEnv       string
+	TargetEnv       string
+	SourceNamespace string
+	TargetNamespace string
+	SourceHelmfile  string
+	TargetHelmfile  string
+	ChartFallback   bool
+	Output          string
+	FailOn          string
+	Out             io.Writer
+	Gitter          gitclient.Interface
+	CommandRunner   cmdrunner.CommandRunner
+	JXClient        versioned.Interface
+	Namespace       string
+	Drifts          []*drift.Drift
+}
+
+// NewCmdDrift creates a command object for the command
+func NewCmdDrift() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "drift",
+		Short:   "Reports the version drift between the charts or applications of two environments or git repositories without creating a Pull Request",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Kind, "kind", "k", KindHelmfile, "the kind of git repositories to compare. Possible values: "+strings.Join(Kinds, ", "))
+	cmd.Flags().StringVarP(&o.SourceEnv, "source-env", "", "", "the name of the source environment whose git URL and namespace are used for helmfile releases")
+	cmd.Flags().StringVarP(&o.TargetEnv, "target-env", "", "", "the name of the target environment whose git URL and namespace are used for helmfile releases")
+	cmd.Flags().StringVarP(&o.SourceNamespace, "source-ns", "", "", "the namespace of the source helmfile releases. If this or --target-ns is specified releases are compared by name so different namespaces can be compared")
+	cmd.Flags().StringVarP(&o.TargetNamespace, "target-ns", "", "", "the namespace of the target helmfile releases")
+	cmd.Flags().StringVarP(&o.SourceHelmfile, "source-helmfile", "", "", "the source helmfile to resolve. If not specified defaults to 'helmfile.yaml' in the source dir")
+	cmd.Flags().StringVarP(&o.TargetHelmfile, "target-helmfile", "", "", "the target helmfile to resolve. If not specified defaults to 'helmfile.yaml' in the target dir")
+	cmd.Flags().BoolVarP(&o.ChartFallback, "chart-fallback", "", false, "if a source Flux HelmRelease has no target HelmRelease with the same name, namespace and target namespace then match by the chart and sourceRef name")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", drift.FormatTable, "the output format. Possible values: "+strings.Join(drift.Formats, ", "))
+	cmd.Flags().StringVarP(&o.FailOn, "fail-on", "", "", "return an error if any target version is at least this far behind. Missing and non semantic versions count as major. Possible values: "+strings.Join(drift.Distances, ", "))
+
+	o.Source.AddFlags(cmd, "source")
+	o.Target.AddFlags(cmd, "target")
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if stringhelpers.StringArrayIndex(Kinds, o.Kind) < 0 {
+		return options.InvalidOption("kind", o.Kind, Kinds)
+	}
+	if o.Output == "" {
+		o.Output = drift.FormatTable
+	}
+	if stringhelpers.StringArrayIndex(drift.Formats, o.Output) < 0 {
+		return options.InvalidOption("output", o.Output, drift.Formats)
+	}
+	if o.FailOn != "" && stringhelpers.StringArrayIndex(drift.Distances, o.FailOn) < 0 {
+		return options.InvalidOption("fail-on", o.FailOn, drift.Distances)
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+	}
+	if o.Gitter == nil {
+		o.Gitter = cli.NewCLIClient("", o.CommandRunner)
+	}
+
+	if o.SourceEnv != "" || o.TargetEnv != "" {
+		err := o.resolveEnvironments()
+		if err != nil {
+			return fmt.Errorf("failed to resolve environments: %w", err)
+		}
+	}
+
+	if o.Target.Dir == "" && o.Target.GitCloneURL == "" {
+		return options.MissingOption(o.Target.OptionPrefix + "-git-url")
+	}
+	var err error
+	o.Source.Dir, err = o.cloneDir(&o.Source, ".")
+	if err != nil {
+		return err
+	}
+	o.Target.Dir, err = o.cloneDir(&o.Target, "")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	o.Drifts, err = o.FindDrifts(o.Source.Dir, o.Target.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to find drifts: %w", err)
+	}
+	err = drift.WriteReport(o.Out, o.Output, o.Drifts)
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return o.CheckFailOn()
+}
+
+// FindDrifts compares the versions in the source and target directories
+func (o *Options) FindDrifts(sourceDir, targetDir string) ([]*drift.Drift, error) {
+	sources, err := o.versions(sourceDir, o.SourceHelmfile, o.SourceNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find source versions: %w", err)
+	}
+	targets, err := o.versions(targetDir, o.TargetHelmfile, o.TargetNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find target versions: %w", err)
+	}
+	return drift.Compare(sources, targets), nil
+}
+
+// CheckFailOn returns an error if any of the drifts are at least as far behind as the --fail-on option
+func (o *Options) CheckFailOn() error {
+	if o.FailOn == "" {
+		return nil
+	}
+	threshold := drift.SeverityOf(o.FailOn)
+	var names []string
+	for _, d := range o.Drifts {
+		if d.Severity() >= threshold {
+			names = append(names, d.Name)
+		}
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("found %d versions at least a %s version behind: %s", len(names), o.FailOn, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func (o *Options) versions(dir, helmfile, namespace string) ([]*drift.Version, error) {
+	switch o.Kind {
+	case KindArgo:
+		return drift.ArgoVersions(dir)
+	case KindFlux:
+		return drift.FluxVersions(dir, o.ChartFallback)
+	default:
+		byName := o.SourceNamespace != "" || o.TargetNamespace != ""
+		return drift.HelmfileVersions(dir, helmfile, namespace, byName)
+	}
+}
+
+// cloneDir returns the dir of the repository cloning the git URL if there is no dir
+func (o *Options) cloneDir(r *gitops.RepositoryOptions, defaultDir string) (string, error) {
+	if r.Dir != "" {
+		return r.Dir, nil
+	}
+	if r.GitCloneURL == "" {
+		return defaultDir, nil
+	}
+	dir, err := gitclient.CloneToDir(o.Gitter, r.GitCloneURL, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to clone %s: %w", r.GitCloneURL, err)
+	}
+	return dir, nil
+}
+
+// resolveEnvironments defaults the git URLs and namespaces from the source and target environments
+func (o *Options) resolveEnvironments() error {
+	var err error
+	o.JXClient, o.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.JXClient, o.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create jx client: %w", err)
+	}
+	envMap, envNames, err := jxenv.GetOrderedEnvironments(o.JXClient, o.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to load environments: %w", err)
+	}
+	resolve := func(envName, option string, r *gitops.RepositoryOptions, ns *string) error {
+		if envName == "" {
+			return nil
+		}
+		env := envMap[envName]
+		if env == nil {
+			return options.InvalidOption(option, envName, envNames)
+		}
+		if *ns == "" {
+			*ns = env.Spec.Namespace
+		}
+		if r.GitCloneURL == "" && r.Dir == "" {
+			r.GitCloneURL = envGitURL(env, envMap)
+		}
+		return nil
+	}
+	err = resolve(o.SourceEnv, "source-env", &o.Source, &o.SourceNamespace)
+	if err != nil {
+		return err
+	}
+	return resolve(o.TargetEnv, "target-env", &o.Target, &o.TargetNamespace)
+}
+
+// envGitURL returns the git URL of the environment defaulting to the dev environment for local environments
+func envGitURL(env *v1.Environment, envMap map[string]*v1.Environment) string {
+	if env.Spec.Source.URL != "" {
+		return env.Spec.Source.URL
+	}
+	if dev := envMap["dev"]; dev != nil {
+		return dev.Spec.Source.URL
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package drift_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/drift"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	// generateTestOutput enable to regenerate the expected output
+	generateTestOutput = false
+)
+
+func TestDrift(t *testing.T) {
+	testDir := "test_data"
+	fileSlice, err := os.ReadDir(testDir)
+	require.NoError(t, err, "failed to read dir %s", testDir)
+
+	for _, f := range fileSlice {
+		if !f.IsDir() {
+			continue
+		}
+		name := f.Name()
+		dir := filepath.Join(testDir, name)
+
+		_, o := drift.NewCmdDrift()
+		o.Kind = name
+		o.Output = "markdown"
+		o.Source.Dir = filepath.Join(dir, "source")
+		o.Target.Dir = filepath.Join(dir, "target")
+		buf := &bytes.Buffer{}
+		o.Out = buf
+
+		err = o.Run()
+		require.NoError(t, err, "failed to run %s", name)
+
+		expectedFile := filepath.Join(dir, "expected.md")
+		if generateTestOutput {
+			err = os.WriteFile(expectedFile, buf.Bytes(), files.DefaultFileWritePermissions)
+			require.NoError(t, err, "failed to save %s", expectedFile)
+			continue
+		}
+		expected, err := os.ReadFile(expectedFile)
+		require.NoError(t, err, "failed to load %s", expectedFile)
+		assert.Equal(t, string(expected), buf.String(), "report for %s", name)
+	}
+}
+
+func TestDriftFailOn(t *testing.T) {
+	testCases := []struct {
+		failOn  string
+		failure bool
+	}{
+		{failOn: "", failure: false},
+		{failOn: "patch", failure: true},
+		{failOn: "minor", failure: true},
+		{failOn: "major", failure: true},
+	}
+	for _, tc := range testCases {
+		_, o := drift.NewCmdDrift()
+		o.Kind = "argo"
+		o.FailOn = tc.failOn
+		o.Source.Dir = filepath.Join("test_data", "argo", "source")
+		o.Target.Dir = filepath.Join("test_data", "argo", "target")
+		o.Out = &bytes.Buffer{}
+
+		err := o.Run()
+		if tc.failure {
+			require.Error(t, err, "should fail for --fail-on %s", tc.failOn)
+		} else {
+			require.NoError(t, err, "should not fail for --fail-on %s", tc.failOn)
+		}
+	}
+
+	_, o := drift.NewCmdDrift()
+	o.Kind = "flux"
+	o.FailOn = "major"
+	o.Source.Dir = filepath.Join("test_data", "flux", "source")
+	o.Target.Dir = filepath.Join("test_data", "flux", "target")
+	o.Out = &bytes.Buffer{}
+	err := o.Run()
+	require.NoError(t, err, "flux versions are not a major version behind")
+
+	o.FailOn = "cheese"
+	err = o.Run()
+	require.Error(t, err, "should fail to validate --fail-on")
+}
+
+func TestDriftNamespaces(t *testing.T) {
+	dir := filepath.Join("test_data", "helmfile", "namespaces")
+	expected, err := os.ReadFile(filepath.Join(dir, "expected.md"))
+	require.NoError(t, err, "failed to load expected report")
+
+	testCases := []struct {
+		name            string
+		sourceNamespace string
+		targetNamespace string
+	}{
+		{name: "both namespaces", sourceNamespace: "jx-staging", targetNamespace: "jx-production"},
+		{name: "source namespace", sourceNamespace: "jx-staging"},
+		{name: "target namespace", targetNamespace: "jx-production"},
+	}
+	for _, tc := range testCases {
+		_, o := drift.NewCmdDrift()
+		o.Kind = drift.KindHelmfile
+		o.Output = "markdown"
+		o.SourceNamespace = tc.sourceNamespace
+		o.TargetNamespace = tc.targetNamespace
+		o.Source.Dir = filepath.Join(dir, "source")
+		o.Target.Dir = filepath.Join(dir, "target")
+		buf := &bytes.Buffer{}
+		o.Out = buf
+
+		err = o.Run()
+		require.NoError(t, err, "failed to run %s", tc.name)
+		assert.Equal(t, string(expected), buf.String(), "report for %s", tc.name)
+	}
+}
+
+func TestDriftFluxChartFallback(t *testing.T) {
+	dir := filepath.Join("test_data", "flux", "namespaces")
+	expected, err := os.ReadFile(filepath.Join(dir, "expected.md"))
+	require.NoError(t, err, "failed to load expected report")
+
+	_, o := drift.NewCmdDrift()
+	o.Kind = drift.KindFlux
+	o.Output = "markdown"
+	o.FailOn = "patch"
+	o.Source.Dir = filepath.Join(dir, "source")
+	o.Target.Dir = filepath.Join(dir, "target")
+	o.Out = &bytes.Buffer{}
+	err = o.Run()
+	require.Error(t, err, "releases in different namespaces should be missing without --chart-fallback")
+
+	o.ChartFallback = true
+	o.FailOn = "minor"
+	buf := &bytes.Buffer{}
+	o.Out = buf
+	err = o.Run()
+	require.NoError(t, err, "releases should be matched by chart with --chart-fallback")
+	assert.Equal(t, string(expected), buf.String(), "report with --chart-fallback")
+}
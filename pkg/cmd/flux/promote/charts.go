@@ -1,20 +1,33 @@
 package promote
 
 import (
+	"fmt"
+
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/fluxcd"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 func (o *Options) ModifyHelmReleaseFiles(dir, chart, sourceRefName, version string) error {
+	sources, err := fluxcd.LoadChartRefSources(dir)
+	if err != nil {
+		return err
+	}
+
+	// the versions of the OCIRepository and HelmChart resources referenced by a spec.chartRef
+	refVersions := map[string]string{}
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		v := fluxcd.GetChartVersion(node, path)
+		v, ref := fluxcd.GetHelmReleaseChartVersion(sources, node, path)
 		if chart != v.Chart {
 			return false, nil
 		}
 		if sourceRefName != "" && sourceRefName != v.SourceRefName {
 			return false, nil
 		}
+		if ref != nil {
+			refVersions[ref.Key()] = version
+			return false, nil
+		}
 		err := fluxcd.SetChartVersion(node, path, version)
 		if err != nil {
 			return false, err
@@ -22,5 +35,32 @@ func (o *Options) ModifyHelmReleaseFiles(dir, chart, sourceRefName, version stri
 		return true, nil
 	}
 
-	return kyamls.ModifyFiles(dir, modifyFn, fluxcd.HelmReleaseKindFilter)
+	err = o.PathFilter.ModifyFiles(dir, modifyFn, fluxcd.HelmReleaseKindFilter)
+	if err != nil {
+		return err
+	}
+	err = fluxcd.SetChartRefSourceVersions(dir, refVersions)
+	if err != nil {
+		return fmt.Errorf("failed to modify the chart references: %w", err)
+	}
+	return nil
+}
+
+// ModifySourceFiles modifies the version of the Flux sources of the given kind with the name or URL
+func (o *Options) ModifySourceFiles(dir, kind, name, url, version string) error {
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		v := fluxcd.GetSourceVersion(node, path)
+		if name != "" && name != v.Name {
+			return false, nil
+		}
+		if url != "" && gitops.TrimGitURLSuffix(url) != gitops.TrimGitURLSuffix(v.URL) {
+			return false, nil
+		}
+		err := fluxcd.SetSourceVersion(node, path, version)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return o.PathFilter.ModifyFiles(dir, modifyFn, fluxcd.SourceKindFilter(kind))
 }
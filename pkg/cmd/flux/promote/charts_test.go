@@ -42,8 +42,18 @@ func TestModifyHelmReleaseFiles(t *testing.T) {
 
 		_, o := promote.NewCmdFluxPromote()
 
-		err = o.ModifyHelmReleaseFiles(srcDir, chart, sourceRefName, version)
-		require.NoError(t, err, "failed to modify files")
+		switch dir {
+		case "sources":
+			err = o.ModifySourceFiles(srcDir, "GitRepository", "", "https://github.com/myorg/my-manifests", "v"+version)
+			require.NoError(t, err, "failed to modify GitRepository files")
+			err = o.ModifySourceFiles(srcDir, "OCIRepository", "my-oci-manifests", "", version)
+			require.NoError(t, err, "failed to modify OCIRepository files")
+			err = o.ModifySourceFiles(srcDir, "Bucket", "my-bucket", "", "v"+version)
+			require.NoError(t, err, "failed to modify Bucket files")
+		default:
+			err = o.ModifyHelmReleaseFiles(srcDir, chart, sourceRefName, version)
+			require.NoError(t, err, "failed to modify files")
+		}
 
 		fileNames, err := os.ReadDir(srcDir)
 		require.NoError(t, err, "failed to read fileNames")
@@ -6,11 +6,15 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/fluxcd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
@@ -26,9 +30,15 @@ type Options struct {
 	Dir           string
 	Chart         string
 	SourceRefName string
-	TargetGitURL  string
+	SourceKind    string
+	SourceName    string
+	SourceURL     string
+	Targets       gitops.PromoteTargetOptions
+	PathFilter    gitops.PathFilter
 	AutoMerge     bool
 	environments.EnvironmentPullRequestOptions
+
+	PromoteTargets []*gitops.PromoteTarget
 }
 
 var (
@@ -38,6 +48,11 @@ var (
 		This command will use the given chart name and version along with an optional sourceRefName of the helm or git repository or bucket to find the HelmRelease resource in the target git repository and create a Pull Request if the version is different.
         This lets you push promotion pull requests into FluxCD repositories as part of your CI release pipeline.
 
+		If the HelmRelease uses a spec.chartRef then the version of the referenced OCIRepository or HelmChart is modified instead.
+
+		To promote plain manifests use --source-kind to modify the spec.ref.tag (or spec.ref.semver) of a GitRepository or OCIRepository or the spec.prefix of a Bucket
+		with the given --source-name or --source-url.
+
 		If you don't supply a version the $VERSION or VERSION file will be used. If you don't supply a chart the current folder name is used.
 `)
 
@@ -45,8 +60,20 @@ var (
 		# lets promote a specific version of a chart with a source ref (repository) name to a git repo
 		jx updatebot flux promote --version v1.2.3 --chart mychart --source-ref-name myrepo --target-git-url https://github.com/myorg/my-flux-repo.git
 
+		# lets promote a specific tag of a GitRepository
+		jx updatebot flux promote --version v1.2.3 --source-kind GitRepository --source-url https://github.com/myorg/my-manifests --target-git-url https://github.com/myorg/my-flux-repo.git
+
 		# lets use the $VERSION env var or a VERSION file in the current dir and detect the chart name from the current folder
 		jx updatebot flux promote --target-git-url https://github.com/myorg/my-flux-repo.git
+
+		# lets promote to many target repositories concurrently
+		jx updatebot flux promote --version v1.2.3 --target-git-url https://github.com/myorg/my-us-repo.git --target-git-url https://github.com/myorg/my-eu-repo.git
+
+		# lets promote to the targets in a YAML file of the form: targets: [{gitUrl: ..., labels: [...], autoMerge: true, paths: [...]}]
+		jx updatebot flux promote --version v1.2.3 --targets-file promote-targets.yaml
+
+		# lets only promote into the staging directory of a monorepo using a sparse checkout
+		jx updatebot flux promote --version v1.2.3 --chart mychart --target-git-url https://github.com/myorg/my-gitops-repo.git --include-path 'envs/staging/**' --sparse-checkout
 	`)
 )
 
@@ -68,7 +95,11 @@ func NewCmdFluxPromote() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory look for the VERSION file")
 	cmd.Flags().StringVarP(&o.Chart, "chart", "c", "", "the name of the chart to promote. If not specified defaults to the current directory name")
 	cmd.Flags().StringVarP(&o.SourceRefName, "source-ref-name", "", "", "the source ref name of the HelmRepository, GitRepository or Bucket containing the helm chart")
-	cmd.Flags().StringVarP(&o.TargetGitURL, "target-git-url", "", "", "the target git URL to create a Pull Request on")
+	cmd.Flags().StringVarP(&o.SourceKind, "source-kind", "", "", fmt.Sprintf("the kind of Flux source to promote rather than a HelmRelease. One of: %s", strings.Join(fluxcd.SourceKinds, ", ")))
+	cmd.Flags().StringVarP(&o.SourceName, "source-name", "", "", "the name of the Flux source to promote when using --source-kind. If neither this or --source-url is specified defaults to the current directory name")
+	cmd.Flags().StringVarP(&o.SourceURL, "source-url", "", "", "the spec.url (or spec.bucketName of a Bucket) of the Flux source to promote when using --source-kind")
+	o.Targets.AddFlags(cmd)
+	o.PathFilter.AddFlags(cmd)
 	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version number to promote. If not specified uses $VERSION or the version file")
 	cmd.Flags().StringVarP(&o.VersionFile, "version-file", "", "", "the file to load the version from if not specified directly or via a $VERSION environment variable. Defaults to VERSION in the current dir")
 	cmd.Flags().StringVarP(&o.VersionPrefix, "version-prefix", "", "v", "the prefix added to the version number that will be used in the Flux CD Application YAML if --version option is not specified and the version is defaulted from $VERSION or the VERSION file")
@@ -92,31 +123,44 @@ func (o *Options) Run() error {
 		return fmt.Errorf("failed to validate options: %w", err)
 	}
 
-	err = o.upgradeRepository(o.TargetGitURL)
-	if err != nil {
-		return fmt.Errorf("failed to create Pull Request on repository %s: %w", o.TargetGitURL, err)
-	}
-	return nil
+	_, err = gitops.CreatePullRequests(o.PromoteTargets, o.upgradeRepository)
+	return err
 }
 
 func (o *Options) Validate() error {
 	var err error
 
-	if o.TargetGitURL == "" {
+	o.PromoteTargets, err = o.Targets.Targets(o.Labels, o.AutoMerge)
+	if err != nil {
+		return fmt.Errorf("failed to load promote targets: %w", err)
+	}
+	if len(o.PromoteTargets) == 0 {
 		return options.MissingOption("target-git-url")
 	}
-	if o.Chart == "" {
-		if o.Dir == "" {
-			o.Dir = "."
+	err = o.PathFilter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate paths: %w", err)
+	}
+	if o.SourceKind != "" {
+		if stringhelpers.StringArrayIndex(fluxcd.SourceKinds, o.SourceKind) < 0 {
+			return options.InvalidOption("source-kind", o.SourceKind, fluxcd.SourceKinds)
 		}
-		abs, err := filepath.Abs(o.Dir)
-		if err != nil {
-			return fmt.Errorf("failed to resolve absolute dir for %s: %w", o.Dir, err)
+		if o.SourceName == "" && o.SourceURL == "" {
+			o.SourceName, err = o.currentDirName()
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		if o.Chart == "" {
+			o.Chart, err = o.currentDirName()
+			if err != nil {
+				return err
+			}
+		}
+		if o.Chart == "" {
+			return options.MissingOption("chart")
 		}
-		_, o.Chart = filepath.Split(abs)
-	}
-	if o.Chart == "" {
-		return options.MissingOption("chart")
 	}
 	addPrefix := false
 	if o.Version == "" {
@@ -158,22 +202,36 @@ func (o *Options) Validate() error {
 	return nil
 }
 
-func (o *Options) upgradeRepository(gitURL string) error {
-	// lets clear the branch name so we create a new one each time in a loop
-	o.BranchName = ""
-
-	if o.CommitTitle == "" {
-		o.CommitTitle = "chore: upgrade pipelines"
+// currentDirName returns the name of the directory
+func (o *Options) currentDirName() (string, error) {
+	if o.Dir == "" {
+		o.Dir = "."
 	}
+	abs, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve absolute dir for %s: %w", o.Dir, err)
+	}
+	_, name := filepath.Split(abs)
+	return name, nil
+}
 
-	o.Function = func() error {
-		dir := o.OutDir
-		return o.ModifyHelmReleaseFiles(dir, o.Chart, o.SourceRefName, o.Version)
+func (o *Options) upgradeRepository(target *gitops.PromoteTarget) (*scm.PullRequest, error) {
+	// lets use a copy of the options so that each target gets its own branch, clone and paths
+	to := *o
+	to.BranchName = ""
+	to.PathFilter = o.PathFilter.WithDirs(target.Paths)
+	to.SparseCheckoutPatterns = to.PathFilter.SparseCheckoutPatterns()
+
+	if to.CommitTitle == "" {
+		to.CommitTitle = "chore: upgrade pipelines"
 	}
 
-	_, err := o.EnvironmentPullRequestOptions.Create(gitURL, "", o.Labels, o.AutoMerge)
-	if err != nil {
-		return fmt.Errorf("failed to create Pull Request on repository %s: %w", gitURL, err)
+	to.Function = func() error {
+		if to.SourceKind != "" {
+			return to.ModifySourceFiles(to.OutDir, to.SourceKind, to.SourceName, to.SourceURL, to.Version)
+		}
+		return to.ModifyHelmReleaseFiles(to.OutDir, to.Chart, to.SourceRefName, to.Version)
 	}
-	return nil
+
+	return to.EnvironmentPullRequestOptions.Create(target.GitURL, "", target.Labels, *target.AutoMerge)
 }
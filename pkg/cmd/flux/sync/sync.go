@@ -2,8 +2,11 @@ package sync
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/changelog"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/fluxcd"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
 	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
@@ -14,6 +17,7 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/input/inputfactory"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/versionstream"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
@@ -25,7 +29,16 @@ var (
 	cmdLong = templates.LongDesc(`
 		Synchronizes some or all HelmRelease versions in an FluxCD git repository to reduce version drift
 
-		Creates a Pull Request on the target GitOps repository.
+		Creates a Pull Request on the target GitOps repository. The Pull Request body lists the changed versions grouped by namespace with links to
+		the source repository of each chart when the prefix of its HelmRepository and its git URL are in the version stream of the source repository.
+		GitRepository resources using a spec.ref.tag also link to the release notes of that tag.
+
+		HelmReleases are matched by their name, release namespace, spec.targetNamespace, chart and sourceRef name. Use --chart-fallback
+		to match HelmReleases which are not found by chart and sourceRef name instead. If several source HelmReleases match with
+		different versions the match is ambiguous so it is reported and the target HelmReleases are not modified.
+
+		Use --source-kind to also synchronise the spec.ref.tag (or spec.ref.semver) of GitRepository and OCIRepository resources
+		and the spec.prefix of Bucket resources with the same kind, namespace and name.
 `)
 
 	cmdExample = templates.Examples(`
@@ -35,11 +48,17 @@ var (
 		# create a Pull Request if any of the versions are out of sync
 		jx updatebot flux sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo
 
-		# create a Pull Request if any of the versions are out of sync including only the given repo URL strings
-		jx updatebot flux sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --repourl-includes wine  --repourl-includes beer 
+		# create a Pull Request if any of the versions are out of sync including only the given chart name strings
+		jx updatebot flux sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --chart-include wine --chart-include beer
+
+		# create a Pull Request if any of the versions are out of sync excluding the charts of the given sourceRef name strings
+		jx updatebot flux sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --source-ref-name-exclude water
 
-		# create a Pull Request if any of the versions are out of sync excluding the given repo URL strings
-		jx updatebot flux sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --repourl-excludes water
+		# create a Pull Request if any of the HelmRelease, GitRepository or OCIRepository versions are out of sync
+		jx updatebot flux sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --source-kind GitRepository --source-kind OCIRepository
+
+		# create a Pull Request only modifying the files in the production directory of the target repo using a sparse checkout
+		jx updatebot flux sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-gitops-repo --include-path 'envs/production/**' --sparse-checkout
 	`)
 )
 
@@ -50,6 +69,7 @@ type Options struct {
 
 	Source             gitops.RepositoryOptions
 	Target             gitops.RepositoryOptions
+	PathFilter         gitops.PathFilter
 	AppFilter          fluxcd.HelmReleaseFilter
 	GitCommitUsername  string
 	GitCommitUserEmail string
@@ -62,7 +82,16 @@ type Options struct {
 	EnvNames           []string
 	VersionStreamDir   string
 	Prefixes           *versionstream.RepositoryPrefixes
+	SourceKinds        []string
+	ChartFallback      bool
 	SourceApplications map[string]*fluxcd.ChartVersion
+	SourceCharts       map[string][]*fluxcd.ChartVersion
+	SourceVersions     map[string]*fluxcd.SourceVersion
+	AmbiguousReleases  []string
+	Changes            []*changelog.Change
+
+	// ambiguousKeys the keys of the source releases which have different versions
+	ambiguousKeys map[string]bool
 }
 
 // NewCmdFluxSync creates a command object for the command
@@ -90,8 +119,13 @@ func NewCmdFluxSync() (*cobra.Command, *Options) {
 	// cmd.Flags().BoolVarP(&o.UpdateOnly, "update-only", "", false, "only update versions in the target environment/namespace - do not add any new charts that are missing")
 	cmd.Flags().BoolVarP(&o.GitCredentials, "git-credentials", "", false, "ensures the git credentials are setup so we can push to git")
 
+	cmd.Flags().BoolVarP(&o.ChartFallback, "chart-fallback", "", false, "if a target HelmRelease has no source HelmRelease with the same name, namespace and target namespace then match by the chart and sourceRef name")
+	cmd.Flags().StringSliceVar(&o.SourceKinds, "source-kind", nil, fmt.Sprintf("the kinds of Flux source whose versions should also be synchronised. Values: %s", strings.Join(fluxcd.SourceKinds, ", ")))
+
 	o.AppFilter.AddFlags(cmd)
 
+	o.PathFilter.AddFlags(cmd)
+
 	o.BaseOptions.AddBaseFlags(cmd)
 	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
 
@@ -109,10 +143,27 @@ func (o *Options) Validate() error {
 	if o.SourceApplications == nil {
 		o.SourceApplications = map[string]*fluxcd.ChartVersion{}
 	}
+	if o.SourceCharts == nil {
+		o.SourceCharts = map[string][]*fluxcd.ChartVersion{}
+	}
 	err := o.BaseOptions.Validate()
 	if err != nil {
 		return fmt.Errorf("failed to validate base options: %w", err)
 	}
+	err = o.AppFilter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate filters: %w", err)
+	}
+	err = o.PathFilter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate paths: %w", err)
+	}
+	o.SparseCheckoutPatterns = o.PathFilter.SparseCheckoutPatterns()
+	for _, k := range o.SourceKinds {
+		if stringhelpers.StringArrayIndex(fluxcd.SourceKinds, k) < 0 {
+			return options.InvalidOption("source-kind", k, fluxcd.SourceKinds)
+		}
+	}
 	if o.Input == nil {
 		o.Input = inputfactory.NewInput(&o.BaseOptions)
 	}
@@ -161,9 +212,13 @@ func (o *Options) Run() error {
 		o.CommitTitle = "chore: sync versions"
 	}
 
+	commitMessage := o.CommitMessage
 	o.Function = func() error {
 		dir := o.OutDir
-		return o.SyncVersions(o.Source.Dir, dir)
+		o.Changes = nil
+		err := o.SyncVersions(o.Source.Dir, dir)
+		o.CommitMessage = changelog.JoinSections(commitMessage, changelog.Markdown(o.Changes))
+		return err
 	}
 
 	_, err = o.EnvironmentPullRequestOptions.Create(gitURL, "", o.Labels, o.AutoMerge)
@@ -175,6 +230,17 @@ func (o *Options) Run() error {
 
 // SyncVersions syncs the source and target versions
 func (o *Options) SyncVersions(sourceDir, targetDir string) error {
+	if o.VersionStreamDir == "" {
+		o.VersionStreamDir = filepath.Join(sourceDir, "versionStream")
+	}
+	if o.Prefixes == nil {
+		var err error
+		o.Prefixes, err = versionstream.GetRepositoryPrefixes(o.VersionStreamDir)
+		if err != nil {
+			return fmt.Errorf("failed to load repository prefixes from version stream dir %s: %w", o.VersionStreamDir, err)
+		}
+	}
+
 	err := o.findSourceApplications(sourceDir)
 	if err != nil {
 		return fmt.Errorf("failed to find source Applications: %w", err)
@@ -184,6 +250,21 @@ func (o *Options) SyncVersions(sourceDir, targetDir string) error {
 	if err != nil {
 		return fmt.Errorf("failed to modify target Applications: %w", err)
 	}
+	for _, text := range o.AmbiguousReleases {
+		log.Logger().Warnf("ignoring ambiguous match: %s", text)
+	}
+
+	if len(o.SourceKinds) == 0 {
+		return nil
+	}
+	err = o.findSourceVersions(sourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to find source %s resources: %w", strings.Join(o.SourceKinds, ", "), err)
+	}
+	err = o.syncSourceVersions(targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to modify target %s resources: %w", strings.Join(o.SourceKinds, ", "), err)
+	}
 	return nil
 }
 
@@ -191,8 +272,18 @@ func (o *Options) findSourceApplications(dir string) error {
 	if o.SourceApplications == nil {
 		o.SourceApplications = map[string]*fluxcd.ChartVersion{}
 	}
+	if o.SourceCharts == nil {
+		o.SourceCharts = map[string][]*fluxcd.ChartVersion{}
+	}
+	if o.ambiguousKeys == nil {
+		o.ambiguousKeys = map[string]bool{}
+	}
+	sources, err := fluxcd.LoadChartRefSources(dir)
+	if err != nil {
+		return err
+	}
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		v := fluxcd.GetChartVersion(node, path)
+		v, _ := fluxcd.GetHelmReleaseChartVersion(sources, node, path)
 		if v.Chart == "" || v.Version == "" {
 			return false, nil
 		}
@@ -200,23 +291,65 @@ func (o *Options) findSourceApplications(dir string) error {
 		log.Logger().Debugf("found source %s", v.String())
 
 		k := v.Key()
-		o.SourceApplications[k] = v
+		existing := o.SourceApplications[k]
+		if existing == nil {
+			o.SourceApplications[k] = v
+		} else if existing.Version != v.Version && !o.ambiguousKeys[k] {
+			o.ambiguousKeys[k] = true
+			o.AmbiguousReleases = append(o.AmbiguousReleases, fmt.Sprintf("source %s has versions %s and %s", v.String(), existing.Version, v.Version))
+		}
+		ck := v.ChartKey()
+		o.SourceCharts[ck] = append(o.SourceCharts[ck], v)
 		return false, nil
 	}
 	return kyamls.ModifyFiles(dir, modifyFn, fluxcd.HelmReleaseKindFilter)
 }
 
 func (o *Options) syncAppVersions(dir string) error {
+	sources, err := fluxcd.LoadChartRefSources(dir)
+	if err != nil {
+		return err
+	}
+	repoURLs, err := fluxcd.LoadRepositoryURLs(dir)
+	if err != nil {
+		return err
+	}
+
+	// the versions of the OCIRepository and HelmChart resources referenced by a spec.chartRef
+	refVersions := map[string]string{}
 	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
-		v := fluxcd.GetChartVersion(node, path)
+		v, ref := fluxcd.GetHelmReleaseChartVersion(sources, node, path)
 		if v.Chart == "" || !o.AppFilter.Matches(v) {
 			return false, nil
 		}
-		k := v.Key()
-		source := o.SourceApplications[k]
+		source := o.findSourceApplication(v)
 		if source == nil {
 			return false, nil
 		}
+		if source.Version != v.Version {
+			gitURL := ""
+			if prefix := o.Prefixes.PrefixForURL(repoURLs[v.SourceRefName]); prefix != "" {
+				gitURL = changelog.ChartGitURL(o.VersionStreamDir, prefix+"/"+v.Chart)
+			}
+			o.Changes = append(o.Changes, &changelog.Change{
+				Namespace: v.Namespace,
+				Name:      v.Name,
+				Chart:     v.Chart,
+				From:      v.Version,
+				To:        source.Version,
+				GitURL:    gitURL,
+			})
+		}
+		if ref != nil {
+			if source.Version != v.Version {
+				rk := ref.Key()
+				if existing, ok := refVersions[rk]; ok && existing != source.Version {
+					o.AmbiguousReleases = append(o.AmbiguousReleases, fmt.Sprintf("%s %s is referenced by releases with versions %s and %s", ref.Kind, rk, existing, source.Version))
+				}
+				refVersions[rk] = source.Version
+			}
+			return false, nil
+		}
 
 		err := fluxcd.SetChartVersion(node, path, source.Version)
 		if err != nil {
@@ -224,5 +357,95 @@ func (o *Options) syncAppVersions(dir string) error {
 		}
 		return true, nil
 	}
-	return kyamls.ModifyFiles(dir, modifyFn, fluxcd.HelmReleaseKindFilter)
+	err = o.PathFilter.ModifyFiles(dir, modifyFn, fluxcd.HelmReleaseKindFilter)
+	if err != nil {
+		return err
+	}
+	err = fluxcd.SetChartRefSourceVersions(dir, refVersions)
+	if err != nil {
+		return fmt.Errorf("failed to modify the chart references: %w", err)
+	}
+	return nil
+}
+
+// findSourceApplication finds the source release for the target release by its name, namespace and target namespace.
+// If there is no match and chart fallback is enabled then the source release with the same chart is used if it is unique
+func (o *Options) findSourceApplication(v *fluxcd.ChartVersion) *fluxcd.ChartVersion {
+	if o.ambiguousKeys[v.Key()] {
+		return nil
+	}
+	source := o.SourceApplications[v.Key()]
+	if source != nil || !o.ChartFallback {
+		return source
+	}
+	candidates := o.SourceCharts[v.ChartKey()]
+	if len(candidates) == 0 {
+		return nil
+	}
+	var versions []string
+	for _, c := range candidates {
+		if stringhelpers.StringArrayIndex(versions, c.Version) < 0 {
+			versions = append(versions, c.Version)
+		}
+	}
+	if len(versions) > 1 {
+		o.AmbiguousReleases = append(o.AmbiguousReleases, fmt.Sprintf("target %s matches source chart versions %s", v.String(), strings.Join(versions, ", ")))
+		return nil
+	}
+	return candidates[0]
+}
+
+func (o *Options) findSourceVersions(dir string) error {
+	if o.SourceVersions == nil {
+		o.SourceVersions = map[string]*fluxcd.SourceVersion{}
+	}
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		v := fluxcd.GetSourceVersion(node, path)
+		if v.Version == "" {
+			return false, nil
+		}
+
+		log.Logger().Debugf("found source %s", v.String())
+
+		o.SourceVersions[v.Key()] = v
+		return false, nil
+	}
+	return kyamls.ModifyFiles(dir, modifyFn, fluxcd.SourceKindFilter(o.SourceKinds...))
+}
+
+func (o *Options) syncSourceVersions(dir string) error {
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		v := fluxcd.GetSourceVersion(node, path)
+		if !o.AppFilter.MatchesSource(v) {
+			return false, nil
+		}
+		source := o.SourceVersions[v.Key()]
+		if source == nil || source.Version == v.Version {
+			return false, nil
+		}
+
+		err := fluxcd.SetSourceVersion(node, path, source.Version)
+		if err != nil {
+			return false, err
+		}
+		gitURL := ""
+		tag := ""
+		if v.Kind == "GitRepository" && strings.HasPrefix(v.URL, "https://") {
+			gitURL = v.URL
+			if !source.Semver {
+				tag = source.Version
+			}
+		}
+		o.Changes = append(o.Changes, &changelog.Change{
+			Namespace: v.Namespace,
+			Name:      v.Name,
+			Chart:     v.Kind,
+			From:      v.Version,
+			To:        source.Version,
+			GitURL:    gitURL,
+			Tag:       tag,
+		})
+		return true, nil
+	}
+	return o.PathFilter.ModifyFiles(dir, modifyFn, fluxcd.SourceKindFilter(o.SourceKinds...))
 }
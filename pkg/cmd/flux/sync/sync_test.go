@@ -10,6 +10,7 @@ import (
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/flux/sync"
 
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -49,6 +50,12 @@ func TestFluxSync(t *testing.T) {
 			o.AppFilter.Chart.Includes = []string{"app1"}
 		case "exclude-app1":
 			o.AppFilter.Chart.Excludes = []string{"app1"}
+		case "include-path":
+			o.PathFilter.Includes = []string{"staging/**"}
+		case "chart-fallback":
+			o.ChartFallback = true
+		case "sources":
+			o.SourceKinds = []string{"GitRepository", "OCIRepository"}
 		}
 
 		srcDir := filepath.Join(dir, "source")
@@ -60,8 +67,35 @@ func TestFluxSync(t *testing.T) {
 
 		err = o.SyncVersions(srcDir, targetDir)
 		require.NoError(t, err, "failed to run sync command")
+		if name == "simple" {
+			require.Len(t, o.Changes, 2, "changes for %s", name)
+			assert.Equal(t, "app1", o.Changes[0].Chart)
+			assert.Equal(t, "2.14.0", o.Changes[0].From)
+			assert.Equal(t, "2.14.2", o.Changes[0].To)
+			assert.Equal(t, "flux-system", o.Changes[0].Namespace)
+			assert.Equal(t, "https://github.com/myorg/app1.git", o.Changes[0].GitURL, "should resolve the git URL using the version stream prefix of the HelmRepository")
+			assert.Empty(t, o.Changes[0].Tag, "the git tag of a chart version is not known")
+		}
+		if name == "sources" {
+			tags := map[string]string{}
+			for _, c := range o.Changes {
+				tags[c.Name] = c.Tag
+			}
+			assert.Equal(t, "v1.3.0", tags["my-manifests"], "should use the spec.ref.tag of the GitRepository")
+			assert.Contains(t, tags, "my-semver-manifests")
+			assert.Empty(t, tags["my-semver-manifests"], "a spec.ref.semver range is not a tag")
+		}
 
 		AssertDirContentsEqual(t, generateTestOutput, verbose, targetDir, expectedDir)
+
+		switch name {
+		case "chart-fallback":
+			assert.Len(t, o.AmbiguousReleases, 1, "should report the ambiguous app2 releases")
+		case "ambiguous-source":
+			assert.Len(t, o.AmbiguousReleases, 1, "should report the ambiguous chartmuseum releases")
+		default:
+			assert.Empty(t, o.AmbiguousReleases, "should not report ambiguous releases for %s", name)
+		}
 	}
 }
 
@@ -0,0 +1,26 @@
+package kustomize
+
+import (
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/kustomize/promote"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/kustomize/sync"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// NewCmdKustomize creates the new command
+func NewCmdKustomize() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "kustomize",
+		Short: "Commands for working with kustomize overlays in git repositories",
+		Run: func(command *cobra.Command, _ []string) {
+			err := command.Help()
+			if err != nil {
+				log.Logger().Error(err.Error())
+			}
+		},
+	}
+	command.AddCommand(cobras.SplitCommand(promote.NewCmdKustomizePromote()))
+	command.AddCommand(cobras.SplitCommand(sync.NewCmdKustomizeSync()))
+	return command
+}
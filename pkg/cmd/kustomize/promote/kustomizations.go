@@ -0,0 +1,23 @@
+package promote
+
+import (
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/kustomize"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ModifyKustomizationFiles modifies the images if --image is specified otherwise the remote resources of the
+// repository URL in the kustomization files in the dir
+func (o *Options) ModifyKustomizationFiles(dir, repoURL, version string) error {
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		if !kustomize.IsKustomization(path) {
+			return false, nil
+		}
+		if o.Image != "" {
+			return kustomize.SetImageVersion(node, path, o.Image, version)
+		}
+		return kustomize.SetResourceRef(node, path, repoURL, version)
+	}
+	return o.PathFilter.ModifyFiles(dir, modifyFn, kyamls.Filter{})
+}
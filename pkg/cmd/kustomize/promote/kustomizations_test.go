@@ -0,0 +1,60 @@
+package promote_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/kustomize/promote"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModifyKustomizationFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Logf("using dir %s\n", tmpDir)
+	err := files.CopyDirOverwrite("test_data", tmpDir)
+	require.NoError(t, err, "failed to copy test data to %s", tmpDir)
+
+	dirNames, err := os.ReadDir(tmpDir)
+	assert.NoError(t, err)
+
+	for _, d := range dirNames {
+		if !d.IsDir() {
+			continue
+		}
+
+		dir := d.Name()
+		srcDir := filepath.Join(tmpDir, dir, "source")
+
+		_, o := promote.NewCmdKustomizePromote()
+
+		repoURL := "https://github.com/myorg/myrepo.git"
+		version := "v1.2.3"
+		if dir == "images" {
+			version = "1.2.3"
+			o.Image = "ghcr.io/myorg/myapp"
+		}
+
+		err = o.ModifyKustomizationFiles(srcDir, repoURL, version)
+		require.NoError(t, err, "failed to modify files")
+
+		err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(path, ".yaml") {
+				return err
+			}
+			rel, err := filepath.Rel(srcDir, path)
+			require.NoError(t, err, "failed to find relative path of %s", path)
+
+			expectedFile := filepath.Join(tmpDir, dir, "expected", rel)
+			err = testhelpers.AssertEqualFileText(t, expectedFile, path)
+			require.NoError(t, err, "cannot assert expected file %s and actual file %s have the same text", expectedFile, path)
+			return nil
+		})
+		require.NoError(t, err, "failed to walk dir %s", srcDir)
+	}
+}
@@ -0,0 +1,196 @@
+package promote
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/gitdiscovery"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+
+	"github.com/spf13/cobra"
+)
+
+// Options the command line options
+type Options struct {
+	Version       string
+	VersionFile   string
+	VersionPrefix string
+	Dir           string
+	SourceGitURL  string
+	Targets       gitops.PromoteTargetOptions
+	PathFilter    gitops.PathFilter
+	Image         string
+	AutoMerge     bool
+	environments.EnvironmentPullRequestOptions
+
+	PromoteTargets []*gitops.PromoteTarget
+}
+
+var (
+	cmdLong = templates.LongDesc(`
+		Promotes a new version into the kustomize overlays of a git repository
+
+		This command will find the kustomization.yaml files in the target git repository which reference the source git repository in a remote resource
+		(e.g. github.com/myorg/myrepo//deploy?ref=v1.2.3) and create a Pull Request changing the ref to the new version.
+
+		If --image is specified then the newTag of the matching images entries are modified instead. If the version starts with sha256: then the digest is modified.
+`)
+
+	cmdExample = templates.Examples(`
+		# lets use the $VERSION env var or a VERSION file in the current dir to change the ref of remote resources of the current git repository
+		jx updatebot kustomize promote --target-git-url https://github.com/myorg/my-gitops-repo.git
+
+		# lets promote a specific version of the remote resources of a git repository
+		jx updatebot kustomize promote --version v1.2.3 --source-git-url https://github.com/myorg/my-manifests.git --target-git-url https://github.com/myorg/my-gitops-repo.git
+
+		# lets promote a new image tag
+		jx updatebot kustomize promote --version 1.2.3 --image ghcr.io/myorg/myapp --target-git-url https://github.com/myorg/my-gitops-repo.git
+
+		# lets promote to many target repositories concurrently
+		jx updatebot kustomize promote --version v1.2.3 --target-git-url https://github.com/myorg/my-us-repo.git --target-git-url https://github.com/myorg/my-eu-repo.git
+
+		# lets promote to the targets in a YAML file of the form: targets: [{gitUrl: ..., labels: [...], autoMerge: true, paths: [...]}]
+		jx updatebot kustomize promote --version v1.2.3 --targets-file promote-targets.yaml
+	`)
+)
+
+// NewCmdKustomizePromote creates a command object
+func NewCmdKustomizePromote() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "promote",
+		Short:   "Promotes a new version into the kustomize overlays of a git repository",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory look for the VERSION file")
+	cmd.Flags().StringVarP(&o.SourceGitURL, "source-git-url", "", "", "the source repo git URL of the remote resources to upgrade. If not specified and --image is not used defaults to the git URL of the current directory")
+	cmd.Flags().StringVarP(&o.Image, "image", "", "", "the name of the image in the kustomization images to set the newTag (or digest) to the version rather than changing remote resources")
+	o.Targets.AddFlags(cmd)
+	o.PathFilter.AddFlags(cmd)
+	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version number to promote. If not specified uses $VERSION or the version file")
+	cmd.Flags().StringVarP(&o.VersionFile, "version-file", "", "", "the file to load the version from if not specified directly or via a $VERSION environment variable. Defaults to VERSION in the current dir")
+	cmd.Flags().StringVarP(&o.VersionPrefix, "version-prefix", "", "v", "the prefix added to the version number that will be used in the kustomization files if --version option is not specified and the version is defaulted from $VERSION or the VERSION file")
+	cmd.Flags().StringSliceVar(&o.Labels, "labels", []string{"promote"}, "a list of labels to apply to the PR")
+
+	cmd.Flags().StringVar(&o.CommitTitle, "pull-request-title", "chore: upgrade the cluster git repository from the version stream", "the PR title")
+	cmd.Flags().StringVar(&o.CommitMessage, "pull-request-body", "", "the PR body")
+	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", false, "should we automatically merge if the PR pipeline is green")
+
+	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.CommitTitle, "commit-title", "", "", "the commit title")
+	cmd.Flags().StringVarP(&o.CommitMessage, "commit-message", "", "", "the commit message")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	_, err = gitops.CreatePullRequests(o.PromoteTargets, o.upgradeRepository)
+	return err
+}
+
+func (o *Options) Validate() error {
+	var err error
+
+	o.PromoteTargets, err = o.Targets.Targets(o.Labels, o.AutoMerge)
+	if err != nil {
+		return fmt.Errorf("failed to load promote targets: %w", err)
+	}
+	if len(o.PromoteTargets) == 0 {
+		return options.MissingOption("target-git-url")
+	}
+	err = o.PathFilter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate paths: %w", err)
+	}
+	if o.SourceGitURL == "" && o.Image == "" {
+		o.SourceGitURL, err = gitdiscovery.FindGitURLFromDir(o.Dir, true)
+		if err != nil {
+			return fmt.Errorf("failed to detect the source repo git URL: %w", err)
+		}
+		o.SourceGitURL = stringhelpers.SanitizeURL(o.SourceGitURL)
+		if o.SourceGitURL == "" {
+			return options.MissingOption("source-git-url")
+		}
+	}
+	addPrefix := false
+	if o.Version == "" {
+		addPrefix = true
+		if o.VersionFile == "" {
+			o.VersionFile = filepath.Join(o.Dir, "VERSION")
+		}
+		exists, err := files.FileExists(o.VersionFile)
+		if err != nil {
+			return fmt.Errorf("failed to check for file %s: %w", o.VersionFile, err)
+		}
+		if exists {
+			data, err := os.ReadFile(o.VersionFile)
+			if err != nil {
+				return fmt.Errorf("failed to read version file %s: %w", o.VersionFile, err)
+			}
+			o.Version = strings.TrimSpace(string(data))
+		} else {
+			log.Logger().Infof("version file %s does not exist", o.VersionFile)
+		}
+	}
+	if o.Version == "" {
+		o.Version = os.Getenv("VERSION")
+		if o.Version == "" {
+			return options.MissingOption("version")
+		}
+	}
+	if addPrefix && o.VersionPrefix != "" && !strings.HasPrefix(o.Version, o.VersionPrefix) {
+		o.Version = o.VersionPrefix + o.Version
+	}
+
+	o.EnvironmentPullRequestOptions.JXClient, o.EnvironmentPullRequestOptions.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.EnvironmentPullRequestOptions.JXClient, o.EnvironmentPullRequestOptions.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create jx client: %w", err)
+	}
+
+	// lazy create the git client
+	o.EnvironmentPullRequestOptions.Git()
+	return nil
+}
+
+func (o *Options) upgradeRepository(target *gitops.PromoteTarget) (*scm.PullRequest, error) {
+	// lets use a copy of the options so that each target gets its own branch, clone and paths
+	to := *o
+	to.BranchName = ""
+	to.PathFilter = o.PathFilter.WithDirs(target.Paths)
+	to.SparseCheckoutPatterns = to.PathFilter.SparseCheckoutPatterns()
+
+	if to.CommitTitle == "" {
+		to.CommitTitle = "chore: upgrade kustomizations"
+	}
+
+	to.Function = func() error {
+		return to.ModifyKustomizationFiles(to.OutDir, to.SourceGitURL, to.Version)
+	}
+
+	return to.EnvironmentPullRequestOptions.Create(target.GitURL, "", target.Labels, *target.AutoMerge)
+}
@@ -0,0 +1,325 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/kustomize"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/input"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/input/inputfactory"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Synchronizes the image versions and remote resource refs of the kustomize overlays in a git repository to reduce version drift
+
+		Images are matched by name and remote resources by their git repository. If the source repository contains different versions
+		of the same image or remote resource the match is ambiguous so it is reported and not modified. Use --source-path to only
+		use the versions of one overlay of the source repository.
+
+		Creates a Pull Request on the target GitOps repository.
+`)
+
+	cmdExample = templates.Examples(`
+		# create a Pull Request if any of the versions in the current directory are different to the target repo
+		jx updatebot kustomize sync --target-git-url https://github.com/myorg/my-production-repo
+
+		# create a Pull Request if any of the versions are out of sync
+		jx updatebot kustomize sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo
+
+		# create a Pull Request using the versions of the staging overlay of the source repository
+		jx updatebot kustomize sync --source-path overlays/staging --target-git-url https://github.com/myorg/my-production-repo
+
+		# create a Pull Request if any of the versions are out of sync including only the given image strings
+		jx updatebot kustomize sync --source-git-url https://github.com/myorg/my-staging-repo --target-git-url https://github.com/myorg/my-production-repo --image-include ghcr.io/myorg
+	`)
+)
+
+// Options the options for synchronising kustomizations
+type Options struct {
+	options.BaseOptions
+	environments.EnvironmentPullRequestOptions
+
+	Source             gitops.RepositoryOptions
+	Target             gitops.RepositoryOptions
+	SourcePath         string
+	Filter             kustomize.KustomizationFilter
+	GitCommitUsername  string
+	GitCommitUserEmail string
+	AutoMerge          bool
+	GitCredentials     bool
+	Labels             []string
+	Input              input.Interface
+	SourceImages       map[string][]*kustomize.ImageVersion
+	SourceResources    map[string][]*kustomize.ResourceRef
+	AmbiguousVersions  []string
+}
+
+// NewCmdKustomizeSync creates a command object for the command
+func NewCmdKustomizeSync() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "sync",
+		Short:   "Synchronizes the image versions and remote resource refs of the kustomize overlays in a git repository to reduce version drift",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.CommitTitle, "pull-request-title", "", "the PR title")
+	cmd.Flags().StringVar(&o.CommitMessage, "pull-request-body", "", "the PR body")
+	cmd.Flags().StringVarP(&o.GitCommitUsername, "git-user-name", "", "", "the user name to git commit")
+	cmd.Flags().StringVarP(&o.GitCommitUserEmail, "git-user-email", "", "", "the user email to git commit")
+	cmd.Flags().StringSliceVar(&o.Labels, "labels", []string{}, "a list of labels to apply to the PR")
+	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", true, "should we automatically merge if the PR pipeline is green")
+	cmd.Flags().BoolVarP(&o.GitCredentials, "git-credentials", "", false, "ensures the git credentials are setup so we can push to git")
+
+	cmd.Flags().StringVarP(&o.SourcePath, "source-path", "", "", "the directory within the source repository to find the versions in. e.g. overlays/staging. Defaults to the whole repository")
+	o.Filter.AddFlags(cmd)
+
+	o.BaseOptions.AddBaseFlags(cmd)
+	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
+
+	cmd.Flags().StringVarP(&o.CommitTitle, "commit-title", "", "", "the commit title")
+	cmd.Flags().StringVarP(&o.CommitMessage, "commit-message", "", "", "the commit message")
+
+	o.Source.AddFlags(cmd, "source")
+	o.Target.AddFlags(cmd, "target")
+
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	err := o.BaseOptions.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate base options: %w", err)
+	}
+	err = o.Filter.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate filters: %w", err)
+	}
+	if filepath.IsAbs(o.SourcePath) || strings.HasPrefix(filepath.Clean(o.SourcePath), "..") {
+		return options.InvalidOptionf("source-path", o.SourcePath, "must be relative to the source repository")
+	}
+	if o.Input == nil {
+		o.Input = inputfactory.NewInput(&o.BaseOptions)
+	}
+
+	// lazy create git
+	o.EnvironmentPullRequestOptions.Git()
+
+	if o.Target.GitCloneURL == "" {
+		return options.MissingOption(o.Target.OptionPrefix + "-git-url")
+	}
+
+	if o.Source.Dir == "" {
+		sourceGitURL := o.Source.GitCloneURL
+		if sourceGitURL == "" {
+			// lets assume current directory is the source
+			o.Source.Dir = "."
+		} else {
+			o.Source.Dir, err = gitclient.CloneToDir(o.Git(), sourceGitURL, "")
+			if err != nil {
+				return fmt.Errorf("failed to clone source cluster %s: %w", sourceGitURL, err)
+			}
+			if o.Source.Dir == "" {
+				return fmt.Errorf("failed to clone the source repository to a directory %s", sourceGitURL)
+			}
+		}
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	gitURL := o.Target.GitCloneURL
+	if gitURL == "" {
+		return fmt.Errorf("no target git clone URL")
+	}
+
+	// lets clear the branch name so we create a new one each time in a loop
+	o.BranchName = ""
+
+	if o.CommitTitle == "" {
+		o.CommitTitle = "chore: sync versions"
+	}
+
+	o.Function = func() error {
+		dir := o.OutDir
+		return o.SyncVersions(o.Source.Dir, dir)
+	}
+
+	_, err = o.EnvironmentPullRequestOptions.Create(gitURL, "", o.Labels, o.AutoMerge)
+	if err != nil {
+		return fmt.Errorf("failed to create Pull Request on repository %s: %w", gitURL, err)
+	}
+	return nil
+}
+
+// SyncVersions syncs the source and target versions. Only the versions in the source path of the source dir are used
+func (o *Options) SyncVersions(sourceDir, targetDir string) error {
+	err := o.findSourceVersions(filepath.Join(sourceDir, o.SourcePath))
+	if err != nil {
+		return fmt.Errorf("failed to find source kustomizations: %w", err)
+	}
+
+	err = o.syncVersions(targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to modify target kustomizations: %w", err)
+	}
+	for _, text := range o.AmbiguousVersions {
+		log.Logger().Warnf("ignoring ambiguous match: %s", text)
+	}
+	return nil
+}
+
+func (o *Options) findSourceVersions(dir string) error {
+	if o.SourceImages == nil {
+		o.SourceImages = map[string][]*kustomize.ImageVersion{}
+	}
+	if o.SourceResources == nil {
+		o.SourceResources = map[string][]*kustomize.ResourceRef{}
+	}
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		if !kustomize.IsKustomization(path) {
+			return false, nil
+		}
+		images, err := kustomize.GetImageVersions(node, path)
+		if err != nil {
+			return false, err
+		}
+		for _, image := range images {
+			if image.Name == "" || image.Version() == "" {
+				continue
+			}
+			log.Logger().Debugf("found source %s", image.String())
+			o.SourceImages[image.Name] = append(o.SourceImages[image.Name], image)
+		}
+
+		resources, err := kustomize.GetResourceRefs(node, path)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range resources {
+			log.Logger().Debugf("found source %s", r.String())
+			o.SourceResources[r.Repository] = append(o.SourceResources[r.Repository], r)
+		}
+		return false, nil
+	}
+	return kyamls.ModifyFiles(dir, modifyFn, kyamls.Filter{})
+}
+
+func (o *Options) syncVersions(dir string) error {
+	imageVersions := map[string]string{}
+	for _, name := range sortedKeys(o.SourceImages) {
+		var versions []string
+		for _, image := range o.SourceImages[name] {
+			versions = append(versions, image.Version())
+		}
+		imageVersions[name] = o.uniqueVersion("image "+name, versions)
+	}
+	resourceRefs := map[string]string{}
+	for _, repository := range sortedKeys(o.SourceResources) {
+		var versions []string
+		for _, r := range o.SourceResources[repository] {
+			versions = append(versions, r.Ref)
+		}
+		resourceRefs[repository] = o.uniqueVersion("remote resource "+repository, versions)
+	}
+
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		if !kustomize.IsKustomization(path) {
+			return false, nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return false, fmt.Errorf("failed to find relative path of %s: %w", path, err)
+		}
+
+		modified := false
+		images, err := kustomize.GetImageVersions(node, path)
+		if err != nil {
+			return false, err
+		}
+		for _, image := range images {
+			version := imageVersions[image.Name]
+			if version == "" || version == image.Version() || !o.Filter.MatchesImage(image, rel) {
+				continue
+			}
+			flag, err := kustomize.SetImageVersion(node, path, image.Name, version)
+			if err != nil {
+				return false, err
+			}
+			modified = modified || flag
+		}
+
+		resources, err := kustomize.GetResourceRefs(node, path)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range resources {
+			ref := resourceRefs[r.Repository]
+			if ref == "" || ref == r.Ref || !o.Filter.MatchesResource(r, rel) {
+				continue
+			}
+			flag, err := kustomize.SetResourceRef(node, path, r.Repository, ref)
+			if err != nil {
+				return false, err
+			}
+			modified = modified || flag
+		}
+		return modified, nil
+	}
+	return kyamls.ModifyFiles(dir, modifyFn, kyamls.Filter{})
+}
+
+// uniqueVersion returns the version if all the versions are the same otherwise the ambiguity is recorded and
+// an empty string is returned
+func (o *Options) uniqueVersion(name string, versions []string) string {
+	unique := map[string]bool{}
+	for _, v := range versions {
+		unique[v] = true
+	}
+	if len(unique) == 1 {
+		return versions[0]
+	}
+	var values []string
+	for v := range unique {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	o.AmbiguousVersions = append(o.AmbiguousVersions, fmt.Sprintf("source %s has versions %s", name, strings.Join(values, ", ")))
+	return ""
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
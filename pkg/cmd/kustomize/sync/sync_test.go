@@ -0,0 +1,78 @@
+package sync_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/kustomize/sync"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKustomizeSync(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	err := files.CopyDirOverwrite("test_data", tmpDir)
+	require.NoError(t, err, "failed to copy test_data to %s", tmpDir)
+
+	fileSlice, err := os.ReadDir(tmpDir)
+	require.NoError(t, err, "failed to read dir %s", tmpDir)
+
+	for _, f := range fileSlice {
+		if !f.IsDir() {
+			continue
+		}
+		name := f.Name()
+		dir := filepath.Join(tmpDir, name)
+
+		_, o := sync.NewCmdKustomizeSync()
+
+		switch name {
+		case "include-image":
+			o.Filter.Image.Includes = []string{"myapp"}
+			o.Filter.RepoURL.Includes = []string{"doesnotexist"}
+		case "source-path":
+			o.SourcePath = filepath.Join("overlays", "staging")
+		}
+
+		srcDir := filepath.Join(dir, "source")
+		targetDir := filepath.Join(dir, "target")
+		expectedDir := filepath.Join("test_data", name, "expected")
+
+		err = o.SyncVersions(srcDir, targetDir)
+		require.NoError(t, err, "failed to run sync command for %s", name)
+
+		if name == "ambiguous" {
+			assert.Equal(t, []string{"source image ghcr.io/myorg/myapp has versions 1.2.0, 1.3.0"}, o.AmbiguousVersions, "ambiguous versions")
+		} else {
+			assert.Empty(t, o.AmbiguousVersions, "should not report ambiguous versions for %s", name)
+		}
+
+		err = filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || !strings.HasSuffix(path, ".yaml") {
+				return err
+			}
+			rel, err := filepath.Rel(targetDir, path)
+			require.NoError(t, err, "failed to find relative path of %s", path)
+
+			expectedFile := filepath.Join(expectedDir, rel)
+			require.FileExists(t, expectedFile)
+
+			resultData, err := os.ReadFile(path)
+			require.NoError(t, err, "failed to load results %s", path)
+			expectData, err := os.ReadFile(expectedFile)
+			require.NoError(t, err, "failed to load results %s", expectedFile)
+
+			if d := cmp.Diff(strings.TrimSpace(string(resultData)), strings.TrimSpace(string(expectData))); d != "" {
+				t.Errorf("modified file %s match expected: %s", path, d)
+			}
+			return nil
+		})
+		require.NoError(t, err, "failed to walk the target dir")
+	}
+}
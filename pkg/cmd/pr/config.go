@@ -0,0 +1,389 @@
+package pr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/updatebotclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// parameterRegex matches the `${name}` expressions of rule template parameters and the escaped `$${name}` expressions
+var parameterRegex = regexp.MustCompile(`\$?\$\{([A-Za-z0-9_.-]+)\}`)
+
+// loadConfig loads the UpdateConfig from the custom resource if specified otherwise from the config file
+func (o *Options) loadConfig() error {
+	if o.ConfigResource != "" {
+		config, err := o.LoadUpdateConfigResource(o.ConfigResource, o.ConfigNamespace)
+		if err != nil {
+			return fmt.Errorf("failed to load config resource %s: %w", o.ConfigResource, err)
+		}
+		o.UpdateConfig = *config
+		return nil
+	}
+
+	// lets default the config file
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
+	}
+	exists, err := files.FileExists(o.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to check for file %s: %w", o.ConfigFile, err)
+	}
+	if !exists {
+		log.Logger().Warnf("file %s does not exist so cannot create any updatebot Pull Requests", o.ConfigFile)
+		return nil
+	}
+	config, err := o.LoadUpdateConfig(o.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config file %s: %w", o.ConfigFile, err)
+	}
+	o.UpdateConfig = *config
+	return nil
+}
+
+// LoadUpdateConfig loads the given config file merging in any included configuration files and resolving any rule templates
+func (o *Options) LoadUpdateConfig(path string) (*v1alpha1.UpdateConfig, error) {
+	defer o.removeIncludeDirs()
+
+	config, err := o.loadUpdateConfigWithIncludes(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = ResolveRuleTemplates(&config.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve rule templates in %s: %w", path, err)
+	}
+	return config, nil
+}
+
+func (o *Options) loadUpdateConfigWithIncludes(path string, parents []string) (*v1alpha1.UpdateConfig, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve absolute path of %s: %w", path, err)
+	}
+	if stringhelpers.StringArrayIndex(parents, abs) >= 0 {
+		return nil, fmt.Errorf("circular include of %s from %s", path, strings.Join(parents, " -> "))
+	}
+
+	config := &v1alpha1.UpdateConfig{}
+	err = yamls.LoadFile(path, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+	}
+	return o.mergeIncludes(config, filepath.Dir(abs), path, append(parents, abs))
+}
+
+// LoadUpdateConfigResource loads the UpdateConfig custom resource of the given name and namespace merging in any
+// included configuration files relative to the current directory and resolving any rule templates
+func (o *Options) LoadUpdateConfigResource(name, ns string) (*v1alpha1.UpdateConfig, error) {
+	defer o.removeIncludeDirs()
+
+	var err error
+	o.UpdatebotClient, ns, err = updatebotclient.LazyCreateUpdatebotClientAndNamespace(o.UpdatebotClient, ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create updatebot client: %w", err)
+	}
+	resource, err := o.UpdatebotClient.UpdatebotV1alpha1().UpdateConfigs(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UpdateConfig %s in namespace %s: %w", name, ns, err)
+	}
+
+	source := fmt.Sprintf("UpdateConfig %s/%s", ns, name)
+	dir, err := filepath.Abs(o.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve absolute path of %s: %w", o.Dir, err)
+	}
+	config, err := o.mergeIncludes(resource.DeepCopy(), dir, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = ResolveRuleTemplates(&config.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve rule templates in %s: %w", source, err)
+	}
+	return config, nil
+}
+
+// mergeIncludes merges any included configuration files of the config with the config itself.
+// Relative includes are resolved from the given dir
+func (o *Options) mergeIncludes(config *v1alpha1.UpdateConfig, dir, source string, parents []string) (*v1alpha1.UpdateConfig, error) {
+	if len(config.Spec.Includes) == 0 {
+		return config, nil
+	}
+
+	answer := &v1alpha1.UpdateConfig{
+		TypeMeta:   config.TypeMeta,
+		ObjectMeta: config.ObjectMeta,
+	}
+	for _, include := range config.Spec.Includes {
+		includePath, err := o.resolveInclude(dir, include)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve include %s in %s: %w", include, source, err)
+		}
+		log.Logger().Debugf("including config file %s from %s", includePath, source)
+
+		included, err := o.loadUpdateConfigWithIncludes(includePath, parents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load include %s in %s: %w", include, source, err)
+		}
+		MergeUpdateConfigSpec(&answer.Spec, &included.Spec)
+	}
+	MergeUpdateConfigSpec(&answer.Spec, &config.Spec)
+	answer.Spec.Includes = nil
+	return answer, nil
+}
+
+// MatchesSourceURL returns true if the UpdateConfig is triggered by releases of the given git URL
+func MatchesSourceURL(config *v1alpha1.UpdateConfig, gitURL string) bool {
+	gitURL = gitops.TrimGitURLSuffix(gitURL)
+	for _, u := range config.Spec.SourceURLs {
+		if strings.EqualFold(gitops.TrimGitURLSuffix(u), gitURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveInclude returns the local file path for the given include, cloning the git repository if required
+func (o *Options) resolveInclude(dir, include string) (string, error) {
+	gitURL, ref, path, err := ParseIncludeRef(include)
+	if err != nil {
+		return "", err
+	}
+	if gitURL == "" {
+		if filepath.IsAbs(path) {
+			return path, nil
+		}
+		return filepath.Join(dir, path), nil
+	}
+
+	if o.includeDirs == nil {
+		o.includeDirs = map[string]string{}
+	}
+	key := gitURL + "@" + ref
+	cloneDir := o.includeDirs[key]
+	if cloneDir == "" {
+		g := o.EnvironmentPullRequestOptions.Git()
+		cloneDir, err = gitclient.CloneToDir(g, gitURL, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to clone %s: %w", gitURL, err)
+		}
+		err = gitclient.Checkout(g, cloneDir, ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to checkout %s of %s: %w", ref, gitURL, err)
+		}
+		o.includeDirs[key] = cloneDir
+	}
+	return filepath.Join(cloneDir, path), nil
+}
+
+// removeIncludeDirs removes the git clones of the included configuration files once they have been loaded
+func (o *Options) removeIncludeDirs() {
+	for _, dir := range o.includeDirs {
+		err := os.RemoveAll(dir)
+		if err != nil {
+			log.Logger().Warnf("failed to remove include clone %s: %s", dir, err.Error())
+		}
+	}
+	o.includeDirs = nil
+}
+
+// ParseIncludeRef parses an include of the form `gitURL@ref:path` returning the git URL, ref and path.
+// If the include is a local file path then the git URL and ref are blank.
+func ParseIncludeRef(include string) (gitURL, ref, path string, err error) {
+	if !strings.Contains(include, "://") && !strings.HasPrefix(include, "git@") {
+		return "", "", include, nil
+	}
+
+	// the user part of the URL such as git@ or user@ comes before the host
+	minIdx := strings.Index(include, "://") + 1
+	if strings.HasPrefix(include, "git@") {
+		minIdx = len("git@")
+	}
+	idx := strings.LastIndex(include, "@")
+	if idx < minIdx {
+		return "", "", "", fmt.Errorf("include %s should be of the form gitURL@ref:path", include)
+	}
+	gitURL = include[0:idx]
+	remaining := include[idx+1:]
+	ref, path, found := strings.Cut(remaining, ":")
+	if !found || ref == "" || path == "" {
+		return "", "", "", fmt.Errorf("include %s should be of the form gitURL@ref:path", include)
+	}
+	return gitURL, ref, path, nil
+}
+
+// MergeUpdateConfigSpec merges the overlay into the given spec. The rules and upstreams of the overlay are appended,
+// rule templates of the overlay replace those of the same name and any labels on the overlay replace the existing labels
+func MergeUpdateConfigSpec(spec, overlay *v1alpha1.UpdateConfigSpec) {
+	if len(overlay.PullRequestLabels) > 0 {
+		spec.PullRequestLabels = overlay.PullRequestLabels
+	}
+	for _, u := range overlay.SourceURLs {
+		spec.SourceURLs = stringhelpers.EnsureStringArrayContains(spec.SourceURLs, u)
+	}
+	for i := range overlay.RuleTemplates {
+		t := overlay.RuleTemplates[i]
+		found := false
+		for j := range spec.RuleTemplates {
+			if spec.RuleTemplates[j].Name == t.Name {
+				spec.RuleTemplates[j] = t
+				found = true
+				break
+			}
+		}
+		if !found {
+			spec.RuleTemplates = append(spec.RuleTemplates, t)
+		}
+	}
+	spec.Rules = append(spec.Rules, overlay.Rules...)
+	spec.Upstreams = append(spec.Upstreams, overlay.Upstreams...)
+}
+
+// ResolveRuleTemplates replaces any rules which refer to a template with the template rule and the rule's parameters
+func ResolveRuleTemplates(spec *v1alpha1.UpdateConfigSpec) error {
+	err := resolveRuleTemplates(spec, spec.Rules)
+	if err != nil {
+		return err
+	}
+	for i := range spec.Upstreams {
+		err = resolveRuleTemplates(spec, spec.Upstreams[i].Rules)
+		if err != nil {
+			return fmt.Errorf("failed to resolve rules of upstream #%d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func resolveRuleTemplates(spec *v1alpha1.UpdateConfigSpec, rules []v1alpha1.Rule) error {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Template == "" {
+			continue
+		}
+		var template *v1alpha1.RuleTemplate
+		for j := range spec.RuleTemplates {
+			if spec.RuleTemplates[j].Name == rule.Template {
+				template = &spec.RuleTemplates[j]
+				break
+			}
+		}
+		if template == nil {
+			return fmt.Errorf("rule #%d refers to unknown rule template %s", i, rule.Template)
+		}
+		resolved, err := applyRuleTemplate(template, rule)
+		if err != nil {
+			return fmt.Errorf("failed to apply rule template %s to rule #%d: %w", rule.Template, i, err)
+		}
+		rules[i] = *resolved
+	}
+	return nil
+}
+
+func applyRuleTemplate(template *v1alpha1.RuleTemplate, rule *v1alpha1.Rule) (*v1alpha1.Rule, error) {
+	parameters := map[string]string{}
+	for k, v := range template.Parameters {
+		parameters[k] = v
+	}
+	for k, v := range rule.Parameters {
+		parameters[k] = v
+	}
+
+	answer, err := substituteParameters(&template.Rule, parameters)
+	if err != nil {
+		return nil, err
+	}
+	answer.Template = ""
+	answer.Parameters = nil
+	if len(rule.URLs) > 0 {
+		answer.URLs = rule.URLs
+	}
+	answer.Changes = append(answer.Changes, rule.Changes...)
+	for _, assignee := range rule.PullRequestAssignees {
+		answer.PullRequestAssignees = stringhelpers.EnsureStringArrayContains(answer.PullRequestAssignees, assignee)
+	}
+	answer.Fork = answer.Fork || rule.Fork
+	answer.ReusePullRequest = answer.ReusePullRequest || rule.ReusePullRequest
+	answer.SparseCheckout = answer.SparseCheckout || rule.SparseCheckout
+	answer.AssignAuthorToPullRequests = answer.AssignAuthorToPullRequests || rule.AssignAuthorToPullRequests
+	return answer, nil
+}
+
+// substituteParameters returns a copy of the rule with any `${name}` expressions in its text values replaced. Any
+// `$${name}` expressions are replaced with `${name}` so that text such as shell variables can be passed through
+func substituteParameters(rule *v1alpha1.Rule, parameters map[string]string) (*v1alpha1.Rule, error) {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal rule: %w", err)
+	}
+	var values interface{}
+	err = json.Unmarshal(data, &values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule: %w", err)
+	}
+
+	undefined := map[string]bool{}
+	values = replaceText(values, func(text string) string {
+		return parameterRegex.ReplaceAllStringFunc(text, func(expression string) string {
+			if strings.HasPrefix(expression, "$$") {
+				return expression[1:]
+			}
+			name := expression[2 : len(expression)-1]
+			value, ok := parameters[name]
+			if !ok {
+				undefined[name] = true
+				return expression
+			}
+			return value
+		})
+	})
+	if len(undefined) > 0 {
+		var names []string
+		for k := range undefined {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined parameters: %s (use $${name} for text which is not a parameter)", strings.Join(names, ", "))
+	}
+
+	data, err = json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal rule: %w", err)
+	}
+	answer := &v1alpha1.Rule{}
+	err = json.Unmarshal(data, answer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rule: %w", err)
+	}
+	return answer, nil
+}
+
+func replaceText(value interface{}, replace func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return replace(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = replaceText(v[i], replace)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = replaceText(v[k], replace)
+		}
+	}
+	return value
+}
@@ -0,0 +1,258 @@
+package pr_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const sharedConfig = `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  pullRequestLabels:
+    - shared
+  ruleTemplates:
+    - name: image
+      parameters:
+        image: jx-cli
+      rule:
+        urls:
+          - https://github.com/myorg/default
+        changes:
+          - regex:
+              pattern: "image: ${image}:(.*)$"
+              files:
+                - "${file}"
+  rules:
+    - urls:
+        - https://github.com/myorg/shared
+      changes:
+        - command:
+            name: make
+`
+
+const repoConfig = `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  includes:
+    - %s
+  rules:
+    - template: image
+      urls:
+        - https://github.com/myorg/myrepo
+      parameters:
+        file: values.yaml
+`
+
+func TestLoadUpdateConfigIncludes(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sharedFile := filepath.Join(tmpDir, "shared", "updatebot.yaml")
+	writeFile(t, sharedFile, sharedConfig)
+
+	configFile := filepath.Join(tmpDir, "repo", ".jx", "updatebot.yaml")
+	writeFile(t, configFile, fmt.Sprintf(repoConfig, "../../shared/updatebot.yaml"))
+
+	o := &pr.Options{}
+	config, err := o.LoadUpdateConfig(configFile)
+	require.NoError(t, err, "failed to load %s", configFile)
+
+	spec := config.Spec
+	assert.Empty(t, spec.Includes)
+	assert.Equal(t, []string{"shared"}, spec.PullRequestLabels)
+	require.Len(t, spec.Rules, 2)
+	assert.Equal(t, []string{"https://github.com/myorg/shared"}, spec.Rules[0].URLs)
+
+	rule := spec.Rules[1]
+	assert.Empty(t, rule.Template)
+	assert.Equal(t, []string{"https://github.com/myorg/myrepo"}, rule.URLs)
+	require.Len(t, rule.Changes, 1)
+	require.NotNil(t, rule.Changes[0].Regex)
+	assert.Equal(t, "image: jx-cli:(.*)$", rule.Changes[0].Regex.Pattern)
+	assert.Equal(t, []string{"values.yaml"}, rule.Changes[0].Regex.Globs)
+}
+
+func TestLoadUpdateConfigGitInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	repoDir := filepath.Join(tmpDir, "central")
+	writeFile(t, filepath.Join(repoDir, "config", "updatebot.yaml"), sharedConfig)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+		{"tag", "v1.0.0"},
+	} {
+		_, err := cmdrunner.QuietCommandRunner(&cmdrunner.Command{Dir: repoDir, Name: "git", Args: args})
+		require.NoError(t, err, "failed to run git %v", args)
+	}
+
+	configFile := filepath.Join(tmpDir, "repo", ".jx", "updatebot.yaml")
+	writeFile(t, configFile, fmt.Sprintf(repoConfig, "file://"+repoDir+"@v1.0.0:config/updatebot.yaml"))
+
+	var cloneDirs []string
+	o := &pr.Options{}
+	o.CommandRunner = func(c *cmdrunner.Command) (string, error) {
+		if len(c.Args) > 0 && c.Args[0] == "clone" {
+			cloneDirs = append(cloneDirs, c.Args[len(c.Args)-1])
+		}
+		return cmdrunner.QuietCommandRunner(c)
+	}
+	config, err := o.LoadUpdateConfig(configFile)
+	require.NoError(t, err, "failed to load %s", configFile)
+
+	require.Len(t, config.Spec.Rules, 2)
+	assert.Equal(t, []string{"https://github.com/myorg/myrepo"}, config.Spec.Rules[1].URLs)
+
+	require.NotEmpty(t, cloneDirs, "should have cloned the include")
+	for _, dir := range cloneDirs {
+		assert.NoDirExists(t, dir, "should have removed the include clone")
+	}
+}
+
+func TestLoadUpdateConfigUndefinedParameters(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sharedFile := filepath.Join(tmpDir, "shared", "updatebot.yaml")
+	writeFile(t, sharedFile, sharedConfig)
+
+	configFile := filepath.Join(tmpDir, "repo", ".jx", "updatebot.yaml")
+	writeFile(t, configFile, strings.ReplaceAll(fmt.Sprintf(repoConfig, "../../shared/updatebot.yaml"), "file: values.yaml", "image: jx-boot"))
+
+	o := &pr.Options{}
+	_, err := o.LoadUpdateConfig(configFile)
+	require.Error(t, err, "should fail to load %s", configFile)
+	assert.Contains(t, err.Error(), "undefined parameters: file")
+}
+
+func TestLoadUpdateConfigEscapedParameters(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".jx", "updatebot.yaml")
+	writeFile(t, configFile, `apiVersion: updatebot.jenkins-x.io/v1alpha1
+kind: UpdateConfig
+spec:
+  ruleTemplates:
+    - name: script
+      parameters:
+        script: ./upgrade.sh
+      rule:
+        changes:
+          - command:
+              name: sh
+              args:
+                - -c
+                - "${script} $${VERSION}"
+  rules:
+    - template: script
+      urls:
+        - https://github.com/myorg/myrepo
+`)
+
+	o := &pr.Options{}
+	config, err := o.LoadUpdateConfig(configFile)
+	require.NoError(t, err, "failed to load %s", configFile)
+
+	require.Len(t, config.Spec.Rules, 1)
+	require.Len(t, config.Spec.Rules[0].Changes, 1)
+	require.NotNil(t, config.Spec.Rules[0].Changes[0].Command)
+	assert.Equal(t, []string{"-c", "./upgrade.sh ${VERSION}"}, config.Spec.Rules[0].Changes[0].Command.Args)
+}
+
+func TestParseIncludeRef(t *testing.T) {
+	testCases := []struct {
+		include string
+		gitURL  string
+		ref     string
+		path    string
+		fails   bool
+	}{
+		{
+			include: "../shared/updatebot.yaml",
+			path:    "../shared/updatebot.yaml",
+		},
+		{
+			include: "https://github.com/myorg/updatebot-rules.git@v1.2.3:rules/go.yaml",
+			gitURL:  "https://github.com/myorg/updatebot-rules.git",
+			ref:     "v1.2.3",
+			path:    "rules/go.yaml",
+		},
+		{
+			include: "git@github.com:myorg/updatebot-rules.git@main:updatebot.yaml",
+			gitURL:  "git@github.com:myorg/updatebot-rules.git",
+			ref:     "main",
+			path:    "updatebot.yaml",
+		},
+		{
+			include: "https://github.com/myorg/updatebot-rules.git",
+			fails:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		gitURL, ref, path, err := pr.ParseIncludeRef(tc.include)
+		if tc.fails {
+			require.Error(t, err, "should fail to parse %s", tc.include)
+			continue
+		}
+		require.NoError(t, err, "failed to parse %s", tc.include)
+		assert.Equal(t, tc.gitURL, gitURL, "gitURL for %s", tc.include)
+		assert.Equal(t, tc.ref, ref, "ref for %s", tc.include)
+		assert.Equal(t, tc.path, path, "path for %s", tc.include)
+	}
+}
+
+func writeFile(t *testing.T, path, text string) {
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create dir for %s", path)
+	err = os.WriteFile(path, []byte(text), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to write %s", path)
+}
+
+func TestLoadUpdateConfigResource(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile(t, filepath.Join(tmpDir, "shared", "updatebot.yaml"), sharedConfig)
+
+	ns := "jx"
+	resource := &v1alpha1.UpdateConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myconfig",
+			Namespace: ns,
+		},
+		Spec: v1alpha1.UpdateConfigSpec{
+			Includes: []string{"shared/updatebot.yaml"},
+			Rules: []v1alpha1.Rule{
+				{
+					Template:   "image",
+					URLs:       []string{"https://github.com/myorg/myrepo"},
+					Parameters: map[string]string{"file": "values.yaml"},
+				},
+			},
+		},
+	}
+
+	o := &pr.Options{
+		Dir:             tmpDir,
+		UpdatebotClient: fake.NewSimpleClientset(resource),
+	}
+	config, err := o.LoadUpdateConfigResource("myconfig", ns)
+	require.NoError(t, err, "failed to load UpdateConfig resource")
+
+	assert.Equal(t, "myconfig", config.Name)
+	assert.Equal(t, []string{"shared"}, config.Spec.PullRequestLabels)
+	require.Len(t, config.Spec.Rules, 2)
+	require.Len(t, config.Spec.Rules[1].Changes, 1)
+	assert.Equal(t, []string{"values.yaml"}, config.Spec.Rules[1].Changes[0].Regex.Globs)
+
+	_, err = o.LoadUpdateConfigResource("does-not-exist", ns)
+	require.Error(t, err, "should fail to load a missing UpdateConfig")
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/client/clientset/versioned"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
@@ -25,7 +26,6 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
 	"github.com/spf13/cobra"
@@ -45,6 +45,8 @@ type Options struct {
 
 	Dir                string
 	ConfigFile         string
+	ConfigResource     string
+	ConfigNamespace    string
 	Version            string
 	VersionFile        string
 	AddChangelog       string
@@ -62,6 +64,9 @@ type Options struct {
 	Helmer             helmer.Helmer
 	GraphQLClient      *githubv4.Client
 	UpdateConfig       v1alpha1.UpdateConfig
+	UpdatebotClient    versioned.Interface
+
+	includeDirs map[string]string
 }
 
 // NewCmdPullRequest creates a command object for the command
@@ -79,6 +84,8 @@ func NewCmdPullRequest() (*cobra.Command, *Options) {
 	}
 	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory look for the VERSION file")
 	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.ConfigResource, "config-resource", "", "", "the name of an UpdateConfig custom resource in the cluster to load instead of the config file")
+	cmd.Flags().StringVarP(&o.ConfigNamespace, "config-namespace", "", "", "the namespace of the UpdateConfig custom resource. Defaults to the current namespace")
 	cmd.Flags().StringVarP(&o.Version, "version", "", "", "the version number to promote. If not specified uses $VERSION or the version file")
 	cmd.Flags().StringVarP(&o.VersionFile, "version-file", "", "", "the file to load the version from if not specified directly or via a $VERSION environment variable. Defaults to VERSION in the current dir")
 	cmd.Flags().StringVarP(&o.Application, "app", "a", "", "the Application to promote. Used for informational purposes")
@@ -170,21 +177,9 @@ func (o *Options) Validate() error {
 		}
 	}
 
-	// lets default the config file
-	if o.ConfigFile == "" {
-		o.ConfigFile = filepath.Join(o.Dir, ".jx", "updatebot.yaml")
-	}
-	exists, err := files.FileExists(o.ConfigFile)
+	err := o.loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to check for file %s: %w", o.ConfigFile, err)
-	}
-	if exists {
-		err = yamls.LoadFile(o.ConfigFile, &o.UpdateConfig)
-		if err != nil {
-			return fmt.Errorf("failed to load config file %s: %w", o.ConfigFile, err)
-		}
-	} else {
-		log.Logger().Warnf("file %s does not exist so cannot create any updatebot Pull Requests", o.ConfigFile)
+		return err
 	}
 
 	if len(o.Labels) == 0 {
@@ -142,7 +142,7 @@ func (o *Options) applyVersionStreamCharts(dir string, vs *v1alpha1.VersionStrea
 			if strings.HasPrefix(ci.RepoURL, "oci://") {
 				// shim for lack of support for searching OCI charts in helm cli
 				ociRepo := scm.Join(ci.RepoURL, n)
-				version, err = ociFindLatestVersion(ociRepo, upperLimit)
+				version, err = OCIFindLatestVersion(ociRepo, upperLimit)
 				if err != nil {
 					return fmt.Errorf("failed to search for chart %s: %w", ociRepo, err)
 				}
@@ -205,8 +205,9 @@ func (o *Options) applyVersionStreamCharts(dir string, vs *v1alpha1.VersionStrea
 	return nil
 }
 
+// OCIFindLatestVersion returns the latest semantic version tag of the OCI repository below any upper limit.
 // This method only returns the minimal answer needed
-func ociFindLatestVersion(ociRepo string, upperLimit *semver.Version) (string, error) {
+func OCIFindLatestVersion(ociRepo string, upperLimit *semver.Version) (string, error) {
 	repo, err := remote.NewRepository(strings.TrimPrefix(ociRepo, "oci://"))
 	if err != nil {
 		return "", err
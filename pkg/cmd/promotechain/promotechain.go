@@ -0,0 +1,456 @@
+package promotechain
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	argosync "github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/argo/sync"
+	fluxsync "github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/flux/sync"
+	kustomizesync "github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/kustomize/sync"
+	helmfilesync "github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/sync"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// DefaultLabel the default label of the Pull Requests created by the promotion chain
+	DefaultLabel = "promote-chain"
+
+	// KindHelmfile a stage using helmfiles
+	KindHelmfile = "helmfile"
+
+	// KindArgo a stage using ArgoCD Applications
+	KindArgo = "argo"
+
+	// KindFlux a stage using Flux HelmReleases
+	KindFlux = "flux"
+
+	// KindKustomize a stage using kustomize overlays
+	KindKustomize = "kustomize"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Promotes versions through the ordered stages of a promotion (e.g. staging then canary then production)
+
+		Each time the command runs every stage is checked in turn. If the gate of the previous stage passes then a Pull Request is created
+		synchronizing the versions of the previous stage into the stage. The gate of a stage passes if:
+
+		* there are no open promotion Pull Requests on its repository
+		* the last Pull Request merged into its repository (by the time of its merge commit) is older than the soak duration
+		* the health check command succeeds
+
+		A stage which already has an open promotion Pull Request is skipped. The state is derived from the Pull Requests of the
+		git repositories so the command can be run periodically (e.g. from a CronJob) or whenever a Pull Request merges.
+`)
+
+	cmdExample = templates.Examples(`
+		# promote the versions through the stages in .jx/promotion.yaml
+		jx updatebot promote-chain
+
+		# show which stages would be promoted without creating any Pull Requests
+		jx updatebot promote-chain --dry-run
+`)
+)
+
+// Options the options for the command
+type Options struct {
+	environments.EnvironmentPullRequestOptions
+
+	Dir        string
+	ConfigFile string
+	DryRun     bool
+	Promotion  v1alpha1.Promotion
+
+	// RunStage promotes the versions of the previous stage into the stage. Defaults to running the sync command of the stage kind
+	RunStage func(previous, stage *v1alpha1.PromotionStage) error
+
+	// Now returns the current time
+	Now func() time.Time
+}
+
+// NewCmdPromoteChain creates a command object for the command
+func NewCmdPromoteChain() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "promote-chain",
+		Short:   "Promotes versions through the ordered stages of a promotion when the gate of the previous stage passes",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to look for the .jx/promotion.yaml file")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the promotion config file. If none specified defaults to .jx/promotion.yaml")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "only log the stages which would be promoted without creating any Pull Requests")
+	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.ConfigFile == "" {
+		o.ConfigFile = filepath.Join(o.Dir, ".jx", "promotion.yaml")
+	}
+	if len(o.Promotion.Spec.Stages) == 0 {
+		exists, err := files.FileExists(o.ConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to check if file exists %s: %w", o.ConfigFile, err)
+		}
+		if !exists {
+			return options.InvalidOptionf("config-file", o.ConfigFile, "file does not exist")
+		}
+		err = yamls.LoadFile(o.ConfigFile, &o.Promotion)
+		if err != nil {
+			return fmt.Errorf("failed to load promotion file %s: %w", o.ConfigFile, err)
+		}
+	}
+	if len(o.Promotion.Spec.Labels) == 0 {
+		o.Promotion.Spec.Labels = []string{DefaultLabel}
+	}
+	for i := range o.Promotion.Spec.Stages {
+		stage := &o.Promotion.Spec.Stages[i]
+		if stage.Name == "" {
+			stage.Name = fmt.Sprintf("stage-%d", i+1)
+		}
+		if stage.Kind == "" {
+			stage.Kind = KindHelmfile
+		}
+		switch stage.Kind {
+		case KindHelmfile, KindArgo, KindFlux, KindKustomize:
+		default:
+			return fmt.Errorf("unsupported kind %s for stage %s", stage.Kind, stage.Name)
+		}
+		if stage.GitURL == "" {
+			return fmt.Errorf("missing gitUrl for stage %s", stage.Name)
+		}
+		if stage.Gate.Soak != "" {
+			_, err := time.ParseDuration(stage.Gate.Soak)
+			if err != nil {
+				return fmt.Errorf("invalid soak %s for stage %s: %w", stage.Gate.Soak, stage.Name, err)
+			}
+		}
+	}
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.DefaultCommandRunner
+	}
+	if o.RunStage == nil {
+		o.RunStage = o.SyncStage
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	return nil
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate: %w", err)
+	}
+
+	stages := o.Promotion.Spec.Stages
+	var failed []string
+	for i := 1; i < len(stages); i++ {
+		previous := &stages[i-1]
+		stage := &stages[i]
+		err = o.PromoteStage(previous, stage)
+		if err != nil {
+			log.Logger().Errorf("failed to promote stage %s: %s", stage.Name, err.Error())
+			failed = append(failed, stage.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to promote stages: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// PromoteStage promotes the versions of the previous stage into the stage if the gate of the previous stage passes
+func (o *Options) PromoteStage(previous, stage *v1alpha1.PromotionStage) error {
+	pending, err := o.OpenPullRequest(stage)
+	if err != nil {
+		return fmt.Errorf("failed to find open Pull Requests of stage %s: %w", stage.Name, err)
+	}
+	if pending != nil {
+		log.Logger().Infof("stage %s is waiting for Pull Request %s to merge", info(stage.Name), info(pending.Link))
+		return nil
+	}
+
+	reason, err := o.GateReason(previous)
+	if err != nil {
+		return fmt.Errorf("failed to check the gate of stage %s: %w", previous.Name, err)
+	}
+	if reason != "" {
+		log.Logger().Infof("not promoting %s to %s: %s", info(previous.Name), info(stage.Name), reason)
+		return nil
+	}
+
+	if o.DryRun {
+		log.Logger().Infof("would promote %s to %s", info(previous.Name), info(stage.Name))
+		return nil
+	}
+	log.Logger().Infof("promoting %s to %s", info(previous.Name), info(stage.Name))
+	return o.RunStage(previous, stage)
+}
+
+// GateReason returns the reason why the gate of the stage has not passed or an empty string if it has passed
+func (o *Options) GateReason(stage *v1alpha1.PromotionStage) (string, error) {
+	gate := &stage.Gate
+	pending, err := o.OpenPullRequest(stage)
+	if err != nil {
+		return "", fmt.Errorf("failed to find open Pull Requests: %w", err)
+	}
+	if pending != nil {
+		return fmt.Sprintf("Pull Request %s has not merged", pending.Link), nil
+	}
+
+	if gate.Soak != "" {
+		soak, err := time.ParseDuration(gate.Soak)
+		if err != nil {
+			return "", fmt.Errorf("invalid soak %s: %w", gate.Soak, err)
+		}
+		merged, mergedAt, err := o.LastMergedPullRequest(stage)
+		if err != nil {
+			return "", fmt.Errorf("failed to find merged Pull Requests: %w", err)
+		}
+		if merged == nil {
+			log.Logger().Infof("no merged Pull Requests found on stage %s so it has soaked", info(stage.Name))
+		} else {
+			age := o.Now().Sub(mergedAt)
+			if age < soak {
+				return fmt.Sprintf("Pull Request %s merged %s ago which is less than the soak of %s", merged.Link, age.Round(time.Second), gate.Soak), nil
+			}
+		}
+	}
+
+	if gate.HealthCheck != nil {
+		err = o.runHealthCheck(stage, gate.HealthCheck)
+		if err != nil {
+			return fmt.Sprintf("health check failed: %s", err.Error()), nil
+		}
+	}
+	return "", nil
+}
+
+// OpenPullRequest returns the first open Pull Request with the promotion labels on the repository of the stage
+func (o *Options) OpenPullRequest(stage *v1alpha1.PromotionStage) (*scm.PullRequest, error) {
+	prs, err := o.listPullRequests(stage, &scm.PullRequestListOptions{Open: true, Size: 100})
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if !pr.Closed && !pr.Merged && o.hasPromotionLabel(pr) {
+			return pr, nil
+		}
+	}
+	return nil, nil
+}
+
+// LastMergedPullRequest returns the most recently merged Pull Request on the repository of the stage and the time it
+// merged. Any merged Pull Request counts rather than only those with the promotion labels as the versions of the first
+// stage usually come from other Pull Requests (e.g. created by jx promote) and any change merged into a stage restarts its soak
+func (o *Options) LastMergedPullRequest(stage *v1alpha1.PromotionStage) (*scm.PullRequest, time.Time, error) {
+	scmClient, repoFullName, err := o.stageScmClient(stage)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	ctx := context.TODO()
+	var merged []*scm.PullRequest
+	opts := &scm.PullRequestListOptions{Closed: true, Page: 1, Size: 100}
+	for {
+		prs, _, err := scmClient.PullRequests.List(ctx, repoFullName, opts)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to list Pull Requests of %s: %w", repoFullName, err)
+		}
+		for _, pr := range prs {
+			if pr.Merged {
+				merged = append(merged, pr)
+			}
+		}
+		if len(prs) < opts.Size {
+			break
+		}
+		opts.Page++
+	}
+
+	// a Pull Request can't merge after its last update so once the last update is before the latest merge found
+	// the remaining Pull Requests merged earlier
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Updated.After(merged[j].Updated)
+	})
+	var answer *scm.PullRequest
+	var answerMergedAt time.Time
+	for _, pr := range merged {
+		if answer != nil && pr.Updated.Before(answerMergedAt) {
+			break
+		}
+		mergedAt, err := o.mergedAt(ctx, scmClient, repoFullName, pr)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if answer == nil || mergedAt.After(answerMergedAt) {
+			answer = pr
+			answerMergedAt = mergedAt
+		}
+	}
+	return answer, answerMergedAt, nil
+}
+
+// mergedAt returns the commit time of the merge commit of the Pull Request. The last update of the Pull Request is
+// used if there is no merge commit as comments and labels added after the merge can only make it later
+func (o *Options) mergedAt(ctx context.Context, scmClient *scm.Client, repoFullName string, pr *scm.PullRequest) (time.Time, error) {
+	if pr.MergeSha == "" {
+		return pr.Updated, nil
+	}
+	commit, _, err := scmClient.Git.FindCommit(ctx, repoFullName, pr.MergeSha)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to find merge commit %s of Pull Request %s: %w", pr.MergeSha, pr.Link, err)
+	}
+	if commit == nil || commit.Committer.Date.IsZero() {
+		return pr.Updated, nil
+	}
+	return commit.Committer.Date, nil
+}
+
+// SyncStage synchronizes the versions of the previous stage into the stage using the sync command of the stage kind
+func (o *Options) SyncStage(previous, stage *v1alpha1.PromotionStage) error {
+	labels := append(append([]string{}, o.Promotion.Spec.Labels...), stage.Labels...)
+	autoMerge := stage.AutoMerge == nil || *stage.AutoMerge
+	title := fmt.Sprintf("chore: promote %s to %s", previous.Name, stage.Name)
+
+	switch stage.Kind {
+	case KindArgo:
+		_, so := argosync.NewCmdArgoSync()
+		so.BaseOptions.BatchMode = true
+		so.EnvironmentPullRequestOptions = o.pullRequestOptions(title)
+		so.Source.GitCloneURL = previous.GitURL
+		so.Target.GitCloneURL = stage.GitURL
+		so.Labels = labels
+		so.AutoMerge = autoMerge
+		return so.Run()
+	case KindFlux:
+		_, so := fluxsync.NewCmdFluxSync()
+		so.BaseOptions.BatchMode = true
+		so.EnvironmentPullRequestOptions = o.pullRequestOptions(title)
+		so.Source.GitCloneURL = previous.GitURL
+		so.Target.GitCloneURL = stage.GitURL
+		so.Labels = labels
+		so.AutoMerge = autoMerge
+		return so.Run()
+	case KindKustomize:
+		_, so := kustomizesync.NewCmdKustomizeSync()
+		so.BaseOptions.BatchMode = true
+		so.EnvironmentPullRequestOptions = o.pullRequestOptions(title)
+		so.Source.GitCloneURL = previous.GitURL
+		so.Target.GitCloneURL = stage.GitURL
+		so.Labels = labels
+		so.AutoMerge = autoMerge
+		return so.Run()
+	default:
+		_, so := helmfilesync.NewCmdEnvironmentSync()
+		so.BaseOptions.BatchMode = true
+		so.EnvironmentPullRequestOptions = o.pullRequestOptions(title)
+		so.Source.GitCloneURL = previous.GitURL
+		so.Source.EnvironmentName = previous.Environment
+		so.Source.Namespace = previous.Namespace
+		so.Target.GitCloneURL = stage.GitURL
+		so.Target.EnvironmentName = stage.Environment
+		so.Target.Namespace = stage.Namespace
+		so.Labels = labels
+		so.AutoMerge = autoMerge
+		return so.Run()
+	}
+}
+
+// pullRequestOptions returns the options to create a Pull Request sharing the git and scm clients
+func (o *Options) pullRequestOptions(title string) environments.EnvironmentPullRequestOptions {
+	return environments.EnvironmentPullRequestOptions{
+		ScmClientFactory: o.ScmClientFactory,
+		ScmClient:        o.ScmClient,
+		Gitter:           o.Gitter,
+		CommandRunner:    o.CommandRunner,
+		JXClient:         o.JXClient,
+		Namespace:        o.Namespace,
+		BatchMode:        true,
+		CommitTitle:      title,
+	}
+}
+
+func (o *Options) listPullRequests(stage *v1alpha1.PromotionStage, opts *scm.PullRequestListOptions) ([]*scm.PullRequest, error) {
+	scmClient, repoFullName, err := o.stageScmClient(stage)
+	if err != nil {
+		return nil, err
+	}
+	prs, _, err := scmClient.PullRequests.List(context.TODO(), repoFullName, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Pull Requests of %s: %w", repoFullName, err)
+	}
+	return prs, nil
+}
+
+// stageScmClient returns the scm client and full name of the repository of the stage
+func (o *Options) stageScmClient(stage *v1alpha1.PromotionStage) (*scm.Client, string, error) {
+	scmClient, repoFullName, err := o.GetScmClient(stage.GitURL, o.ScmClientFactory.GitKind)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create the scm client for %s: %w", stage.GitURL, err)
+	}
+	if o.ScmClient != nil {
+		scmClient = o.ScmClient
+	}
+	return scmClient, repoFullName, nil
+}
+
+func (o *Options) hasPromotionLabel(pr *scm.PullRequest) bool {
+	for _, l := range pr.Labels {
+		for _, name := range o.Promotion.Spec.Labels {
+			if l != nil && l.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (o *Options) runHealthCheck(stage *v1alpha1.PromotionStage, command *v1alpha1.Command) error {
+	c := &cmdrunner.Command{
+		Dir:  o.Dir,
+		Name: command.Name,
+		Args: command.Args,
+		Out:  os.Stdout,
+		Err:  os.Stderr,
+		Env: map[string]string{
+			"PROMOTION_STAGE":   stage.Name,
+			"PROMOTION_GIT_URL": stage.GitURL,
+		},
+	}
+	for _, e := range command.Env {
+		c.Env[e.Name] = e.Value
+	}
+	_, err := o.CommandRunner(c)
+	if err != nil {
+		return fmt.Errorf("failed to run command %s: %w", c.CLI(), err)
+	}
+	return nil
+}
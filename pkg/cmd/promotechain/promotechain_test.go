@@ -0,0 +1,213 @@
+package promotechain_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/promotechain"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoteChain(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// lots of older merged Pull Requests so that the latest one is on the second page
+	var oldPullRequests []*scm.PullRequest
+	for i := 0; i < 100; i++ {
+		oldPullRequests = append(oldPullRequests, mergedPullRequest("staging", "", now.Add(-48*time.Hour)))
+	}
+
+	testCases := []struct {
+		name        string
+		prs         []*scm.PullRequest
+		merges      map[string]time.Time
+		healthy     bool
+		expected    []string
+		expectedCmd int
+	}{
+		{
+			name: "promotes-after-soak",
+			prs: []*scm.PullRequest{
+				mergedPullRequest("staging", "abc", now.Add(-2*time.Hour), "promote"),
+			},
+			merges:      map[string]time.Time{"abc": now.Add(-2 * time.Hour)},
+			healthy:     true,
+			expected:    []string{"staging->canary", "canary->production"},
+			expectedCmd: 1,
+		},
+		{
+			name: "waits-for-soak",
+			prs: []*scm.PullRequest{
+				mergedPullRequest("staging", "abc", now.Add(-10*time.Minute), "promote"),
+			},
+			merges:      map[string]time.Time{"abc": now.Add(-10 * time.Minute)},
+			healthy:     true,
+			expected:    []string{"canary->production"},
+			expectedCmd: 1,
+		},
+		{
+			name: "soak-uses-merge-commit-time",
+			prs: []*scm.PullRequest{
+				mergedPullRequest("staging", "abc", now.Add(-5*time.Minute), "promote"),
+			},
+			merges:      map[string]time.Time{"abc": now.Add(-2 * time.Hour)},
+			healthy:     true,
+			expected:    []string{"staging->canary", "canary->production"},
+			expectedCmd: 1,
+		},
+		{
+			name:        "waits-for-soak-on-later-page",
+			prs:         append(append([]*scm.PullRequest{}, oldPullRequests...), mergedPullRequest("staging", "abc", now.Add(-10*time.Minute))),
+			merges:      map[string]time.Time{"abc": now.Add(-10 * time.Minute)},
+			healthy:     true,
+			expected:    []string{"canary->production"},
+			expectedCmd: 1,
+		},
+		{
+			name: "waits-for-previous-merge",
+			prs: []*scm.PullRequest{
+				openPullRequest("canary", promotechain.DefaultLabel),
+			},
+			healthy: true,
+		},
+		{
+			name: "ignores-other-open-pull-requests",
+			prs: []*scm.PullRequest{
+				openPullRequest("canary", "dependencies"),
+			},
+			healthy:     true,
+			expected:    []string{"staging->canary", "canary->production"},
+			expectedCmd: 1,
+		},
+		{
+			name:        "health-check-fails",
+			healthy:     false,
+			expected:    []string{"staging->canary"},
+			expectedCmd: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scmClient, fakeData := fake.NewDefault()
+			for i, pr := range tc.prs {
+				pr.Number = i + 1
+				fakeData.PullRequests[pr.Number] = pr
+			}
+			for sha, merged := range tc.merges {
+				fakeData.Commits[sha] = &scm.Commit{Sha: sha, Committer: scm.Signature{Date: merged}}
+			}
+
+			_, o := promotechain.NewCmdPromoteChain()
+			o.ScmClient = scmClient
+			o.ScmClientFactory.ScmClient = scmClient
+			o.Now = func() time.Time {
+				return now
+			}
+			o.Promotion.Spec.Stages = []v1alpha1.PromotionStage{
+				{
+					Name:   "staging",
+					GitURL: "https://github.com/myorg/staging.git",
+					Gate: v1alpha1.PromotionGate{
+						Soak: "1h",
+					},
+				},
+				{
+					Name:   "canary",
+					Kind:   promotechain.KindArgo,
+					GitURL: "https://github.com/myorg/canary.git",
+					Gate: v1alpha1.PromotionGate{
+						HealthCheck: &v1alpha1.Command{
+							Name: "./health.sh",
+						},
+					},
+				},
+				{
+					Name:   "production",
+					Kind:   promotechain.KindArgo,
+					GitURL: "https://github.com/myorg/production.git",
+					Gate: v1alpha1.PromotionGate{
+						HealthCheck: &v1alpha1.Command{
+							Name: "./never-run.sh",
+						},
+					},
+				},
+			}
+
+			var commands []*cmdrunner.Command
+			o.CommandRunner = func(c *cmdrunner.Command) (string, error) {
+				commands = append(commands, c)
+				if !tc.healthy {
+					return "", fmt.Errorf("unhealthy")
+				}
+				return "", nil
+			}
+
+			var promoted []string
+			o.RunStage = func(previous, stage *v1alpha1.PromotionStage) error {
+				promoted = append(promoted, previous.Name+"->"+stage.Name)
+				return nil
+			}
+
+			err := o.Run()
+			require.NoError(t, err, "failed to run promote-chain")
+
+			assert.Equal(t, tc.expected, promoted, "promoted stages")
+			require.Len(t, commands, tc.expectedCmd, "health check commands")
+			for _, c := range commands {
+				assert.Equal(t, "./health.sh", c.Name, "health check command")
+				assert.Equal(t, "canary", c.Env["PROMOTION_STAGE"], "health check stage")
+			}
+		})
+	}
+}
+
+func TestPromoteChainInvalidConfig(t *testing.T) {
+	_, o := promotechain.NewCmdPromoteChain()
+	o.Promotion.Spec.Stages = []v1alpha1.PromotionStage{
+		{
+			Name:   "staging",
+			Kind:   "cheese",
+			GitURL: "https://github.com/myorg/staging.git",
+		},
+	}
+	err := o.Validate()
+	require.Error(t, err, "should fail to validate an unknown kind")
+
+	_, o = promotechain.NewCmdPromoteChain()
+	o.Dir = t.TempDir()
+	err = o.Validate()
+	require.Error(t, err, "should fail to validate a missing config file")
+}
+
+func mergedPullRequest(repo, mergeSha string, updated time.Time, labels ...string) *scm.PullRequest {
+	pr := openPullRequest(repo, labels...)
+	pr.Closed = true
+	pr.Merged = true
+	pr.MergeSha = mergeSha
+	pr.Updated = updated
+	return pr
+}
+
+func openPullRequest(repo string, labels ...string) *scm.PullRequest {
+	pr := &scm.PullRequest{
+		Base: scm.PullRequestBranch{
+			Repo: scm.Repository{
+				Namespace: "myorg",
+				Name:      repo,
+				FullName:  "myorg/" + repo,
+			},
+		},
+		Link: "https://github.com/myorg/" + repo + "/pull/1",
+	}
+	for _, l := range labels {
+		pr.Labels = append(pr.Labels, &scm.Label{Name: l})
+	}
+	return pr
+}
@@ -2,12 +2,18 @@ package cmd
 
 import (
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/argo"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/controller"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/drift"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/environment"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/flux"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/kustomize"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pipeline"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/promotechain"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/scan"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/sync"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/version"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/webhook"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/rootcmd"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
@@ -27,11 +33,17 @@ func Main() *cobra.Command {
 		},
 	}
 	cmd.AddCommand(argo.NewCmdArgo())
+	cmd.AddCommand(cobras.SplitCommand(controller.NewCmdController()))
+	cmd.AddCommand(cobras.SplitCommand(drift.NewCmdDrift()))
 	cmd.AddCommand(flux.NewCmdFlux())
 	cmd.AddCommand(cobras.SplitCommand(environment.NewCmdUpgradeEnvironment()))
+	cmd.AddCommand(kustomize.NewCmdKustomize())
 	cmd.AddCommand(cobras.SplitCommand(pipeline.NewCmdUpgradePipeline()))
 	cmd.AddCommand(cobras.SplitCommand(pr.NewCmdPullRequest()))
+	cmd.AddCommand(cobras.SplitCommand(promotechain.NewCmdPromoteChain()))
+	cmd.AddCommand(cobras.SplitCommand(scan.NewCmdScan()))
 	cmd.AddCommand(cobras.SplitCommand(sync.NewCmdEnvironmentSync()))
 	cmd.AddCommand(cobras.SplitCommand(version.NewCmdVersion()))
+	cmd.AddCommand(cobras.SplitCommand(webhook.NewCmdWebhook()))
 	return cmd
 }
@@ -0,0 +1,169 @@
+package scan
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Scans the upstreams of the updatebot configuration for their latest versions and creates Pull Requests on any downstream repositories which are behind
+
+		Each upstream can be a git repository, a helm chart, a container image or a go module. The rules of the upstream
+		(or of the configuration if the upstream has none) are applied using the latest version so that only
+		repositories which have changes get a Pull Request. Any 'v' prefix is removed from the latest version
+		whatever the kind of upstream.
+`)
+
+	cmdExample = templates.Examples(`
+		# scan the upstreams in .jx/updatebot.yaml
+		jx updatebot scan
+
+		# only scan upstreams whose name contains 'jx-cli'
+		jx updatebot scan --filter jx-cli
+`)
+)
+
+// Options the options for the command
+type Options struct {
+	pr.Options
+
+	Filter     string
+	GoProxy    string
+	HTTPClient *http.Client
+}
+
+// NewCmdScan creates a command object for the command
+func NewCmdScan() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "scan",
+		Short:   "Scans the upstreams for their latest versions and creates Pull Requests on any downstream repositories which are behind",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", ".", "the directory to look for the .jx/updatebot.yaml file")
+	cmd.Flags().StringVarP(&o.ConfigFile, "config-file", "c", "", "the updatebot config file. If none specified defaults to .jx/updatebot.yaml")
+	cmd.Flags().StringVarP(&o.ConfigResource, "config-resource", "", "", "the name of an UpdateConfig custom resource in the cluster to load instead of the config file")
+	cmd.Flags().StringVarP(&o.ConfigNamespace, "config-namespace", "", "", "the namespace of the UpdateConfig custom resource. Defaults to the current namespace")
+	cmd.Flags().StringVarP(&o.Filter, "filter", "f", "", "the text filter to filter out upstreams to scan")
+	cmd.Flags().StringVarP(&o.GoProxy, "go-proxy", "", "", "the go module proxy to query for go module versions. Defaults to the first proxy in $GOPROXY or https://proxy.golang.org")
+	cmd.Flags().StringVarP(&o.GitCommitUsername, "git-user-name", "", "", "the user name to git commit")
+	cmd.Flags().StringVarP(&o.GitCommitUserEmail, "git-user-email", "", "", "the user email to git commit")
+	cmd.Flags().StringSliceVar(&o.Labels, "labels", []string{}, "a list of labels to apply to the PR")
+	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", true, "should we automatically merge if the PR pipeline is green")
+	cmd.Flags().BoolVarP(&o.GitCredentials, "git-credentials", "", false, "ensures the git credentials are setup so we can push to git")
+	cmd.Flags().StringVarP(&o.BaseBranchName, "base-branch-name", "b", "", "the base branch name to use for new pull requests")
+	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate: %w", err)
+	}
+
+	var failed []string
+	for i := range o.UpdateConfig.Spec.Upstreams {
+		upstream := &o.UpdateConfig.Spec.Upstreams[i]
+		name := UpstreamName(upstream)
+		if o.Filter != "" && !strings.Contains(name, o.Filter) {
+			continue
+		}
+		err = o.ScanUpstream(upstream, name)
+		if err != nil {
+			log.Logger().Errorf("failed to scan upstream %s: %s", name, err.Error())
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to scan upstreams: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	// the version comes from each upstream
+	o.NoVersion = true
+	if o.GoProxy == "" {
+		o.GoProxy = defaultGoProxy(os.Getenv("GOPROXY"))
+	}
+	if o.HTTPClient == nil {
+		o.HTTPClient = &http.Client{Timeout: goProxyTimeout}
+	}
+	return o.Options.Validate()
+}
+
+// ScanUpstream resolves the latest version of the upstream and applies its rules
+func (o *Options) ScanUpstream(upstream *v1alpha1.Upstream, name string) error {
+	version, err := o.LatestVersion(upstream)
+	if err != nil {
+		return fmt.Errorf("failed to find latest version: %w", err)
+	}
+	if version == "" {
+		log.Logger().Warnf("no version found for upstream %s", name)
+		return nil
+	}
+	log.Logger().Infof("latest version of %s is %s", info(name), info(version))
+
+	rules := upstream.Rules
+	if len(rules) == 0 {
+		rules = o.UpdateConfig.Spec.Rules
+	}
+	baseBranchName := o.BaseBranchName
+	for i := range rules {
+		rule := rules[i]
+
+		o.Version = version
+		o.Application = name
+		o.CommitTitle = fmt.Sprintf("chore(deps): upgrade %s to version %s", name, version)
+		o.CommitMessage = fmt.Sprintf("upgrades %s to the latest version %s\n", name, version)
+
+		err = o.ProcessRule(&rule, i)
+		if err != nil {
+			return fmt.Errorf("failed to process rule #%d: %w", i, err)
+		}
+		err = o.ProcessAndCreatePullRequests(&rule, baseBranchName, o.Labels, o.AutoMerge)
+		if err != nil {
+			return fmt.Errorf("failed to create Pull Requests for rule #%d: %w", i, err)
+		}
+	}
+	o.BaseBranchName = baseBranchName
+	return nil
+}
+
+// UpstreamName returns the name of the upstream defaulting to its git URL, chart, image or go module
+func UpstreamName(upstream *v1alpha1.Upstream) string {
+	switch {
+	case upstream.Name != "":
+		return upstream.Name
+	case upstream.GitURL != "":
+		return upstream.GitURL
+	case upstream.Chart != "":
+		return upstream.Chart
+	case upstream.Image != "":
+		return upstream.Image
+	default:
+		return upstream.GoModule
+	}
+}
@@ -0,0 +1,84 @@
+package scan_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/scan"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner/fakerunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/helmer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	runner := &fakerunner.FakeRunner{
+		CommandRunner: func(c *cmdrunner.Command) (string, error) {
+			if c.Name == "git" && len(c.Args) > 0 && c.Args[0] == "push" {
+				t.Logf("faking command %s in dir %s\n", c.CLI(), c.Dir)
+				return "", nil
+			}
+
+			// lets really git clone but then fake out all other commands
+			return cmdrunner.DefaultCommandRunner(c)
+		},
+	}
+
+	goProxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/github.com/jenkins-x/go-scm/@latest" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Version":"v1.15.30","Time":"2026-10-01T00:00:00Z"}`))
+	}))
+	defer goProxy.Close()
+
+	scmClient, fakeData := fake.NewDefault()
+	fakeData.Releases = map[string]map[int]*scm.Release{
+		"jenkins-x/jx": {
+			1: {ID: 1, Tag: "v3.10.1"},
+			2: {ID: 2, Tag: "v3.11.0"},
+			3: {ID: 3, Tag: "v3.12.0-rc1", Prerelease: true},
+			4: {ID: 4, Tag: "v4.0.0", Draft: true},
+		},
+	}
+
+	fakeHelmer := helmer.NewFakeHelmer()
+	fakeHelmer.ChartsAllVersions["jenkins-x-charts.github.io/jx-build-controller"] = []helmer.ChartSummary{
+		{
+			ChartVersion: "0.5.2",
+		},
+		{
+			ChartVersion: "0.5.1",
+		},
+	}
+
+	_, o := scan.NewCmdScan()
+	o.Dir = "test_data"
+	o.GoProxy = goProxy.URL
+	o.CommandRunner = runner.Run
+	o.ScmClient = scmClient
+	o.ScmClientFactory.ScmClient = scmClient
+	o.ScmClientFactory.NoWriteGitCredentialsFile = true
+	o.Helmer = fakeHelmer
+	o.EnvironmentPullRequestOptions.ScmClientFactory.GitServerURL = "https://github.com"
+	o.EnvironmentPullRequestOptions.ScmClientFactory.GitToken = "dummytoken"
+	o.EnvironmentPullRequestOptions.ScmClientFactory.GitUsername = "dummyuser"
+
+	err := o.Run()
+	require.NoError(t, err, "failed to run scan")
+
+	var titles []string
+	for _, pr := range fakeData.PullRequests {
+		titles = append(titles, pr.Title)
+	}
+	assert.ElementsMatch(t, []string{
+		"chore(deps): upgrade jx-cli to version 3.11.0",
+		"chore(deps): upgrade jx-build-controller to version 0.5.2",
+		"chore(deps): upgrade github.com/jenkins-x/go-scm to version 1.15.30",
+	}, titles, "pull request titles")
+}
@@ -0,0 +1,160 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/helmer"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"golang.org/x/mod/module"
+)
+
+const (
+	defaultGoProxyURL = "https://proxy.golang.org"
+
+	// goProxyTimeout the maximum time to wait for the go module proxy
+	goProxyTimeout = 30 * time.Second
+)
+
+// LatestVersion returns the latest version of the upstream without any 'v' prefix
+func (o *Options) LatestVersion(upstream *v1alpha1.Upstream) (string, error) {
+	var version string
+	var err error
+	switch {
+	case upstream.GitURL != "":
+		version, err = o.gitLatestVersion(upstream.GitURL)
+	case upstream.Chart != "":
+		version, err = o.chartLatestVersion(upstream.ChartRepository, upstream.Chart)
+	case upstream.Image != "":
+		version, err = pr.OCIFindLatestVersion(upstream.Image, nil)
+	case upstream.GoModule != "":
+		version, err = o.goModuleLatestVersion(upstream.GoModule)
+	default:
+		return "", fmt.Errorf("upstream %s has no gitURL, chart, image or goModule", upstream.Name)
+	}
+	return strings.TrimPrefix(version, "v"), err
+}
+
+// gitLatestVersion returns the highest released version of the git repository ignoring drafts and pre-releases
+func (o *Options) gitLatestVersion(gitURL string) (string, error) {
+	scmClient, fullName, err := o.GetScmClient(gitURL, o.GitKind)
+	if err != nil {
+		return "", fmt.Errorf("failed to create ScmClient for %s: %w", gitURL, err)
+	}
+
+	ctx := context.Background()
+	latestVersion := ""
+	var latestFound semver.Version
+	opts := scm.ReleaseListOptions{Page: 1, Size: 100}
+	for {
+		releases, _, err := scmClient.Releases.List(ctx, fullName, opts)
+		if err != nil {
+			if scm.IsScmNotFound(err) {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to list releases of %s: %w", fullName, err)
+		}
+		for _, r := range releases {
+			if r.Draft || r.Prerelease {
+				continue
+			}
+			version, err := semver.ParseTolerant(r.Tag)
+			if err != nil {
+				log.Logger().Debugf("ignore release tag that doesn't look like version: %s", r.Tag)
+				continue
+			}
+			if version.GT(latestFound) {
+				latestFound = version
+				latestVersion = r.Tag
+			}
+		}
+		if len(releases) < opts.Size {
+			break
+		}
+		opts.Page++
+	}
+	return latestVersion, nil
+}
+
+// chartLatestVersion returns the latest version of the chart in the helm or OCI repository
+func (o *Options) chartLatestVersion(repoURL, chart string) (string, error) {
+	if repoURL == "" {
+		return "", fmt.Errorf("no chartRepository for chart %s", chart)
+	}
+	if strings.HasPrefix(repoURL, "oci://") {
+		return pr.OCIFindLatestVersion(scm.Join(repoURL, chart), nil)
+	}
+
+	repoName, err := helmer.AddHelmRepoIfMissing(o.Helmer, repoURL, "", "", "")
+	if err != nil {
+		return "", fmt.Errorf("failed to add helm repository %s: %w", repoURL, err)
+	}
+	err = o.Helmer.UpdateRepo()
+	if err != nil {
+		log.Logger().Warnf("failed to update helm repositories: %s", err.Error())
+	}
+
+	name := scm.Join(repoName, chart)
+	charts, err := o.Helmer.SearchCharts(name, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to search for chart %s: %w", name, err)
+	}
+	if len(charts) == 0 {
+		return "", nil
+	}
+	return charts[0].ChartVersion, nil
+}
+
+// goModuleLatestVersion returns the latest version of the go module from the go module proxy
+func (o *Options) goModuleLatestVersion(modulePath string) (string, error) {
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", fmt.Errorf("invalid go module %s: %w", modulePath, err)
+	}
+	u := fmt.Sprintf("%s/%s/@latest", strings.TrimSuffix(o.GoProxy, "/"), escaped)
+	ctx, cancel := context.WithTimeout(context.Background(), goProxyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request for %s: %w", u, err)
+	}
+	resp, err := o.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to query %s: status %s", u, resp.Status)
+	}
+
+	latest := struct {
+		Version string
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&latest)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse response of %s: %w", u, err)
+	}
+	return latest.Version, nil
+}
+
+// defaultGoProxy returns the first proxy URL of the GOPROXY value
+func defaultGoProxy(goproxy string) string {
+	for _, p := range strings.FieldsFunc(goproxy, func(r rune) bool { return r == ',' || r == '|' }) {
+		p = strings.TrimSpace(p)
+		if p != "" && p != "direct" && p != "off" {
+			return p
+		}
+	}
+	return defaultGoProxyURL
+}
@@ -3,6 +3,8 @@ package sync
 import (
 	"fmt"
 
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 
@@ -21,10 +23,15 @@ type EnvironmentOptions struct {
 
 // AddFlags adds the CLI flags to this object
 func (o *EnvironmentOptions) AddFlags(cmd *cobra.Command, optionsPrefix string) {
+	o.AddRepositoryFlags(cmd, optionsPrefix)
+	cmd.Flags().StringVarP(&o.EnvironmentName, optionsPrefix+"-env", "", "", fmt.Sprintf("the environment name for the %s", optionsPrefix))
+}
+
+// AddRepositoryFlags adds the CLI flags to this object apart from the environment name
+func (o *EnvironmentOptions) AddRepositoryFlags(cmd *cobra.Command, optionsPrefix string) {
 	o.OptionPrefix = optionsPrefix
 	cmd.Flags().StringVarP(&o.GitCloneURL, optionsPrefix+"-git-url", "", "", fmt.Sprintf("git URL to clone for the %s", optionsPrefix))
 	cmd.Flags().StringVarP(&o.Helmfile, optionsPrefix+"-helmfile", "", "", "the helmfile to resolve. If not specified defaults to 'helmfile.yaml' in the git clone dir")
-	cmd.Flags().StringVarP(&o.EnvironmentName, optionsPrefix+"-env", "", "", fmt.Sprintf("the environment name for the %s", optionsPrefix))
 	cmd.Flags().StringVarP(&o.Dir, optionsPrefix+"-dir", "", "", fmt.Sprintf("the directory to use for the git clone for the %s", optionsPrefix))
 	cmd.Flags().StringVarP(&o.Namespace, optionsPrefix+"-ns", "", "", fmt.Sprintf("the namespace for the %s", optionsPrefix))
 }
@@ -33,9 +40,32 @@ func (o *EnvironmentOptions) IsBlank() bool {
 	return o.EnvironmentName == "" && o.GitCloneURL == "" && o.Namespace == ""
 }
 
+// LoadEnvironments lazily loads the Environments from the cluster if they have not already been loaded
+func (o *Options) LoadEnvironments() error {
+	if o.EnvMap != nil {
+		return nil
+	}
+	var err error
+	o.JXClient, o.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.JXClient, o.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to create JX client: %w", err)
+	}
+	o.EnvMap, o.EnvNames, err = jxenv.GetOrderedEnvironments(o.JXClient, o.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to load environments: %w", err)
+	}
+	// lets remove the dev env name as we don't promote to/from it
+	o.EnvNames = stringhelpers.RemoveStringFromSlice(o.EnvNames, "dev")
+	return nil
+}
+
 func (o *Options) ChooseEnvironments() error {
 	var err error
 	if o.Source.IsBlank() {
+		err = o.LoadEnvironments()
+		if err != nil {
+			return err
+		}
 		// lets pick a source environment
 		o.Source.EnvironmentName, err = o.Input.PickNameWithDefault(o.EnvNames, "source environment: ", "", "pick the name of the source Environment you want to sync")
 		if err != nil {
@@ -45,7 +75,11 @@ func (o *Options) ChooseEnvironments() error {
 			return fmt.Errorf("no source environment")
 		}
 	}
-	if o.Target.IsBlank() {
+	if o.Target.IsBlank() && len(o.TargetEnvs) == 0 {
+		err = o.LoadEnvironments()
+		if err != nil {
+			return err
+		}
 		// lets pick a target environment
 		targetEnvNames := o.EnvNames
 		if o.Source.EnvironmentName != "" {
@@ -64,18 +98,75 @@ func (o *Options) ChooseEnvironments() error {
 	if err != nil {
 		return fmt.Errorf("failed to validate the source: %w", err)
 	}
-	err = o.ValidateEnvironment(&o.Target, false)
-	if err != nil {
-		return fmt.Errorf("failed to validate the target: %w", err)
+	return nil
+}
+
+// TargetEnvironments returns the validated target environments. If --target-env is specified there is a target for each
+// environment name where 'all' is expanded to the environments after the source environment in promotion order which
+// requires the source to be an environment
+func (o *Options) TargetEnvironments() ([]*EnvironmentOptions, error) {
+	var names []string
+	for _, name := range o.TargetEnvs {
+		if name != AllEnvironments {
+			names = append(names, name)
+			continue
+		}
+		if o.Source.EnvironmentName == "" {
+			return nil, options.InvalidOptionf("target-env", name, "requires the source environment to be specified with --source-env")
+		}
+		err := o.LoadEnvironments()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, o.downstreamEnvironments()...)
 	}
 
-	// lets validate the setup
-	if o.Source.GitCloneURL == o.Target.GitCloneURL {
-		if o.Source.Namespace == o.Target.Namespace {
-			return fmt.Errorf("cannot use the same source and target git URL and namespace. You must sync with either different repositories or namespaces")
+	var answer []*EnvironmentOptions
+	if len(o.TargetEnvs) == 0 {
+		target := o.Target
+		answer = append(answer, &target)
+	}
+	for _, name := range names {
+		if name == o.Source.EnvironmentName || o.hasTargetEnvironment(answer, name) {
+			continue
 		}
+		target := o.Target
+		target.EnvironmentName = name
+		answer = append(answer, &target)
 	}
-	return nil
+	if len(answer) == 0 {
+		return nil, fmt.Errorf("no target environments after the source environment %s", o.Source.EnvironmentName)
+	}
+
+	for _, target := range answer {
+		err := o.ValidateEnvironment(target, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to validate the target: %w", err)
+		}
+
+		// lets validate the setup
+		if o.Source.GitCloneURL == target.GitCloneURL {
+			if o.Source.Namespace == target.Namespace {
+				return nil, fmt.Errorf("cannot use the same source and target git URL and namespace. You must sync with either different repositories or namespaces")
+			}
+		}
+	}
+	return answer, nil
+}
+
+// downstreamEnvironments returns the environments after the source environment in promotion order
+func (o *Options) downstreamEnvironments() []string {
+	idx := stringhelpers.StringArrayIndex(o.EnvNames, o.Source.EnvironmentName)
+	return o.EnvNames[idx+1:]
+}
+
+func (o *Options) hasTargetEnvironment(targets []*EnvironmentOptions, name string) bool {
+	for _, t := range targets {
+		if t.EnvironmentName == name {
+			return true
+		}
+	}
+	return false
 }
 
 // ValidateEnvironment lets validate we can find the helmfiles for the given
@@ -84,8 +175,16 @@ func (o *Options) ValidateEnvironment(env *EnvironmentOptions, source bool) erro
 	if source {
 		name = "source"
 	}
-	var err error
 	envName := env.EnvironmentName
+	if env.GitCloneURL == "" && envName == "" && env.Namespace == "" {
+		return fmt.Errorf("no %s environment name, git URL or namespace", name)
+	}
+	if env.GitCloneURL == "" {
+		err := o.LoadEnvironments()
+		if err != nil {
+			return err
+		}
+	}
 	if env.GitCloneURL == "" && envName != "" {
 		e := o.EnvMap[envName]
 		if e == nil {
@@ -97,10 +196,8 @@ func (o *Options) ValidateEnvironment(env *EnvironmentOptions, source bool) erro
 		}
 		env.GitCloneURL = e.Spec.Source.URL
 	}
-	if o.Namespace == "" && envName == "" {
-		return fmt.Errorf("no %s environment name or namespace for", name)
-	}
 	if env.GitCloneURL == "" {
+		var err error
 		env.GitCloneURL, err = o.GetDevCloneGitURL()
 		if err != nil {
 			return err
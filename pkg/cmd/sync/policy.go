@@ -0,0 +1,167 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/drift"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+)
+
+// DefaultPolicyFile the file in the target cluster repository containing the version policies
+const DefaultPolicyFile = ".jx/sync-policy.yaml"
+
+// LoadPolicy loads the --policy-file or the default policy file in the target dir if it exists
+func (o *Options) LoadPolicy(targetDir string) (*v1alpha1.SyncPolicy, error) {
+	path := o.PolicyFile
+	if path == "" {
+		path = filepath.Join(targetDir, DefaultPolicyFile)
+		exists, err := files.FileExists(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if file exists %s: %w", path, err)
+		}
+		if !exists {
+			return nil, nil
+		}
+	}
+	policy := &v1alpha1.SyncPolicy{}
+	err := yamls.LoadFile(path, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync policy file %s: %w", path, err)
+	}
+	err = ValidatePolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync policy file %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// ValidatePolicy validates the durations and semantic version jumps of the policy
+func ValidatePolicy(policy *v1alpha1.SyncPolicy) error {
+	policies := []*v1alpha1.VersionPolicy{&policy.Spec.Default}
+	for i := range policy.Spec.Charts {
+		policies = append(policies, &policy.Spec.Charts[i].VersionPolicy)
+	}
+	for _, p := range policies {
+		if p.MinAge != "" {
+			_, err := time.ParseDuration(p.MinAge)
+			if err != nil {
+				return fmt.Errorf("invalid minAge %s: %w", p.MinAge, err)
+			}
+		}
+		if p.MaxJump != "" && stringhelpers.StringArrayIndex(drift.Distances, p.MaxJump) < 0 {
+			return fmt.Errorf("invalid maxJump %s: must be one of %s", p.MaxJump, strings.Join(drift.Distances, ", "))
+		}
+	}
+	return nil
+}
+
+// ChartVersionPolicy returns the policy of the first chart policy matching the chart and namespace or the default policy
+func ChartVersionPolicy(policy *v1alpha1.SyncPolicy, chart, namespace string) *v1alpha1.VersionPolicy {
+	for i := range policy.Spec.Charts {
+		p := &policy.Spec.Charts[i]
+		if p.Namespace != "" && p.Namespace != namespace {
+			continue
+		}
+		if helmfiles.MatchesChartName(chart, p.Chart) {
+			return &p.VersionPolicy
+		}
+	}
+	return &policy.Spec.Default
+}
+
+// PolicyReason returns the reason why the source version of the chart in the source helmfile cannot be synchronized
+// to the target version or an empty string if the version passes the policy
+func (o *Options) PolicyReason(details *helmfiles.ChartDetails, sourceHelmfile, targetVersion string) (string, error) {
+	if o.Policy == nil || details.Version == targetVersion {
+		return "", nil
+	}
+	p := ChartVersionPolicy(o.Policy, details.Chart, details.Namespace)
+	if p.Pinned {
+		return "the chart is pinned", nil
+	}
+	if p.DenyPrerelease {
+		v, err := semver.ParseTolerant(details.Version)
+		if err == nil && len(v.Pre) > 0 {
+			return "prerelease versions are denied", nil
+		}
+	}
+	if p.MaxJump != "" && targetVersion != "" {
+		d := &drift.Drift{Distance: drift.GetDistance(details.Version, targetVersion)}
+		if d.Severity() > drift.SeverityOf(p.MaxJump) {
+			return fmt.Sprintf("the %s change from %s is larger than the maximum %s change", d.Distance, targetVersion, p.MaxJump), nil
+		}
+	}
+	if p.MinAge != "" {
+		minAge, err := time.ParseDuration(p.MinAge)
+		if err != nil {
+			return "", fmt.Errorf("invalid minAge %s: %w", p.MinAge, err)
+		}
+		committed, err := o.VersionCommitTime(sourceHelmfile, details.Version)
+		if err != nil {
+			return "", fmt.Errorf("failed to find when version %s was committed: %w", details.Version, err)
+		}
+		if committed.IsZero() {
+			return "the version has not been committed to the source repository", nil
+		}
+		age := o.Now().Sub(committed)
+		if age < minAge {
+			return fmt.Sprintf("the version was committed %s ago which is less than the minimum age of %s", age.Round(time.Second), p.MinAge), nil
+		}
+	}
+	return "", nil
+}
+
+// VersionCommitTime returns the time of the last commit which added or removed the version in the helmfile using
+// the git history of the source repository
+func (o *Options) VersionCommitTime(path, version string) (time.Time, error) {
+	text, err := o.Git().Command(filepath.Dir(path), "log", "-1", "--format=%cI", "-S", version, "--", filepath.Base(path))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query the git history of %s: %w", path, err)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse commit time %s: %w", text, err)
+	}
+	return t, nil
+}
+
+// findTargetVersions returns the versions of the charts in the target helmfiles indexed by chartKey
+func findTargetVersions(targetHelmfiles []helmfiles.Helmfile) (map[string]string, error) {
+	answer := map[string]string{}
+	for i := range targetHelmfiles {
+		path := targetHelmfiles[i].Filepath
+		helmStates, err := helmfiles.LoadHelmfile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load helmfile %s: %w", path, err)
+		}
+		for _, helmState := range helmStates {
+			for j := range helmState.Releases {
+				rel := &helmState.Releases[j]
+				ns := rel.Namespace
+				if ns == "" {
+					ns = helmState.OverrideNamespace
+				}
+				answer[chartKey(ns, rel.Chart)] = rel.Version
+			}
+		}
+	}
+	return answer, nil
+}
+
+// chartKey returns the key of the chart in the namespace ignoring the repository prefix of the chart
+func chartKey(namespace, chart string) string {
+	_, name := helmfiles.SpitChartName(chart)
+	return namespace + "/" + name
+}
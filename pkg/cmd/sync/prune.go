@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// findSourceKeys returns the chartKey of all the source releases matching the chart filter using the target namespace
+func (o *Options) findSourceKeys(sourceHelmfiles []helmfiles.Helmfile) (map[string]bool, error) {
+	answer := map[string]bool{}
+	for i := range sourceHelmfiles {
+		path := sourceHelmfiles[i].Filepath
+		helmStates, err := helmfiles.LoadHelmfile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load helmfile %s: %w", path, err)
+		}
+		for _, helmState := range helmStates {
+			for j := range helmState.Releases {
+				details := helmfiles.NewChartDetails(helmState, &helmState.Releases[j], o.Prefixes)
+				if !o.ChartFilter.Matches(details) {
+					continue
+				}
+				if o.Target.Namespace != "" {
+					details.Namespace = o.Target.Namespace
+				}
+				answer[chartKey(details.Namespace, details.Chart)] = true
+			}
+		}
+	}
+	return answer, nil
+}
+
+// pruneReleases removes the target releases matching the chart filter which are not in the source releases
+// unless they are in the --prune-keep list
+func (o *Options) pruneReleases(targetDir string, sourceKeys map[string]bool) error {
+	targetHelmfiles, err := helmfiles.GatherHelmfiles(o.Target.Helmfile, targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to gather target helmfiles from %s: %w", targetDir, err)
+	}
+	keep := ChartFilter{Charts: o.PruneKeep}
+	for i := range targetHelmfiles {
+		path := targetHelmfiles[i].Filepath
+		helmStates, err := helmfiles.LoadHelmfile(path)
+		if err != nil {
+			return fmt.Errorf("failed to load helmfile %s: %w", path, err)
+		}
+		modified := false
+		for _, helmState := range helmStates {
+			var releases []state.ReleaseSpec
+			for j := range helmState.Releases {
+				rel := helmState.Releases[j]
+				ns := rel.Namespace
+				if ns == "" {
+					ns = helmState.OverrideNamespace
+				}
+				if !o.isPruned(&rel, ns, sourceKeys, &keep) {
+					releases = append(releases, rel)
+					continue
+				}
+				log.Logger().Infof("removing chart %s in namespace %s as it is not in the source", rel.Chart, ns)
+				o.Pruned = append(o.Pruned, fmt.Sprintf("%s in namespace %s", rel.Chart, ns))
+				modified = true
+			}
+			helmState.Releases = releases
+		}
+		if modified {
+			err = helmfiles.SaveHelmfile(path, helmStates)
+			if err != nil {
+				return fmt.Errorf("failed to save helmfile %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// isPruned returns true if the target release in the namespace should be removed. Charts pinned by the sync policy
+// are never removed
+func (o *Options) isPruned(rel *state.ReleaseSpec, ns string, sourceKeys map[string]bool, keep *ChartFilter) bool {
+	if sourceKeys[chartKey(ns, rel.Chart)] {
+		return false
+	}
+	if o.Policy != nil && ChartVersionPolicy(o.Policy, rel.Chart, ns).Pinned {
+		return false
+	}
+	if len(keep.Charts) > 0 && keep.Matches(&helmfiles.ChartDetails{Chart: rel.Chart}) {
+		return false
+	}
+	// lets match the chart filter using the source namespace
+	filterNamespace := ns
+	if o.Target.Namespace != "" {
+		if ns != o.Target.Namespace {
+			return false
+		}
+		if o.Source.Namespace != "" {
+			filterNamespace = o.Source.Namespace
+		}
+	}
+	return o.ChartFilter.Matches(&helmfiles.ChartDetails{Chart: rel.Chart, Namespace: filterNamespace})
+}
+
+// prunedMarkdown returns the markdown listing the removed releases for the Pull Request body
+func (o *Options) prunedMarkdown() string {
+	if len(o.Pruned) == 0 {
+		return ""
+	}
+	buf := strings.Builder{}
+	buf.WriteString("Removed the releases which are not in the source:\n\n")
+	for _, p := range o.Pruned {
+		buf.WriteString("* " + p + "\n")
+	}
+	return buf.String()
+}
@@ -3,20 +3,25 @@ package sync
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
 	"github.com/jenkins-x-plugins/jx-promote/pkg/environments"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/apis/updatebot/v1alpha1"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/changelog"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
 	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/input"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/input/inputfactory"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxclient"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jxenv"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/versionstream"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 
 	"github.com/spf13/cobra"
 )
@@ -27,8 +32,27 @@ var (
 
 		Supports synchronizing environments or namespaces within the same cluster or namespaces between remote clusters (possibly using different namespaces).
 
+		The Environments are only loaded from the cluster if an environment name is used or no git URL is specified. So you can sync
+		from one git repository to another in a CI job without access to a cluster using the --source-git-url and --target-git-url options.
+
 		Create a Pull Request on the target GitOps repository to apply the changes so that you can review the changes before they happen. 
 		You can use different labels to enable/disable auto-merging.
+
+		If the target repository contains a .jx/sync-policy.yaml file (or --policy-file is specified) then only the chart versions which pass
+		their policy are synchronized. A policy can require a minimum age of the version in the source git history, limit the semantic version
+		change, deny prerelease versions or pin the chart so that it is never changed.
+
+		Use --prune to remove the releases from the target which have been removed from the source. Use --prune-keep for charts which
+		should only exist in the target. Charts pinned by the policy are never removed.
+
+		The Pull Request body lists the changed versions grouped by namespace and any removed releases. Each chart links to its source repository
+		when its git URL is in the version stream and to the release notes of the new version when its git tag exists in that repository.
+
+		Use --sync-values to also copy the values files and set entries of the synchronized releases. Only the values files in the
+		directory of the helmfile of the source release are copied and never those in the version stream or the jx-values.yaml files
+		generated for each environment. Use --values-exclude and --set-exclude to never copy values files and set entries such as
+		secrets, replica counts or ingress hosts which are specific to the target environment. Values files and set entries removed
+		from the source release are not removed from the target release.
 `)
 
 	cmdExample = templates.Examples(`
@@ -41,13 +65,31 @@ var (
 		# synchronizes the apps in 2 namespaces in the dev cluster
 		jx updatebot sync --source-ns jx-staging --target-ns jx-production
 
+		# synchronizes the apps in 2 git repositories without accessing a cluster
+		jx updatebot sync --source-git-url https://github.com/myorg/my-staging-repo --source-ns jx-staging --target-git-url https://github.com/myorg/my-production-repo --target-ns jx-production
+
 
 		# synchronizes the edam and beer charts in 2 of your environments (local or remote)
 		jx updatebot sync --source-env staging --target-env production --charts edam --charts beer
 
+		# synchronizes the apps in staging to the canary and production environments creating a Pull Request for each
+		jx updatebot sync --source-env staging --target-env canary --target-env production
+
+		# synchronizes the apps in staging to all of the environments after staging in promotion order
+		jx updatebot sync --source-env staging --target-env all
+
+		# synchronizes the apps in 2 of your environments removing any apps which are no longer in the source except the target only monitoring chart
+		jx updatebot sync --source-env staging --target-env production --prune --prune-keep monitoring
+
+		# synchronizes the chart versions and values files except secrets and replica counts
+		jx updatebot sync --source-env staging --target-env production --sync-values --values-exclude '**/secrets.yaml' --set-exclude replicaCount
+
 	`)
 )
 
+// AllEnvironments the target environment name for all the environments after the source environment in promotion order
+const AllEnvironments = "all"
+
 // Options the options for upgrading a cluster
 type Options struct {
 	options.BaseOptions
@@ -55,6 +97,7 @@ type Options struct {
 
 	Source             EnvironmentOptions
 	Target             EnvironmentOptions
+	TargetEnvs         []string
 	ChartFilter        ChartFilter
 	GitCommitUsername  string
 	GitCommitUserEmail string
@@ -69,6 +112,18 @@ type Options struct {
 	SourceDir          string
 	VersionStreamDir   string
 	Prefixes           *versionstream.RepositoryPrefixes
+	PolicyFile         string
+	Policy             *v1alpha1.SyncPolicy
+	PolicySkipped      []string
+	Now                func() time.Time
+	SyncValues         bool
+	Prune              bool
+	PruneKeep          []string
+	Pruned             []string
+	Changes            []*changelog.Change
+	Tags               map[string]string
+	ValuesFilter       gitops.PathFilter
+	SetFilter          gitops.PathFilter
 }
 
 type ChartFilter struct {
@@ -102,6 +157,14 @@ func NewCmdEnvironmentSync() (*cobra.Command, *Options) {
 	cmd.Flags().BoolVarP(&o.NoVersion, "no-version", "", false, "disables validation on requiring a '--version' option or environment variable to be required")
 	cmd.Flags().BoolVarP(&o.UpdateOnly, "update-only", "", false, "only update versions in the target environment/namespace - do not add any new charts that are missing")
 	cmd.Flags().BoolVarP(&o.GitCredentials, "git-credentials", "", false, "ensures the git credentials are setup so we can push to git")
+	cmd.Flags().BoolVarP(&o.Prune, "prune", "", false, "remove the releases in the target environment/namespace matching the chart filters which are not in the source")
+	cmd.Flags().StringSliceVar(&o.PruneKeep, "prune-keep", []string{}, "names of charts which should never be removed by --prune. Can be local chart name (without prefix) or the full name with prefix")
+	cmd.Flags().BoolVarP(&o.SyncValues, "sync-values", "", false, "also synchronize the values files and set entries of the synchronized releases. jx-values.yaml files are never synchronized and values files and set entries removed from the source are not removed from the target")
+	cmd.Flags().StringArrayVarP(&o.ValuesFilter.Includes, "values-include", "", nil, "the glob patterns of the values file paths relative to the target repository to synchronize with --sync-values. e.g. helmfiles/**/values.yaml.gotmpl")
+	cmd.Flags().StringArrayVarP(&o.ValuesFilter.Excludes, "values-exclude", "", nil, "the glob patterns of the values file paths relative to the target repository which should never be synchronized with --sync-values. e.g. **/secrets.yaml")
+	cmd.Flags().StringArrayVarP(&o.SetFilter.Includes, "set-include", "", nil, "the glob patterns of the set entry names to synchronize with --sync-values. e.g. image.*")
+	cmd.Flags().StringArrayVarP(&o.SetFilter.Excludes, "set-exclude", "", nil, "the glob patterns of the set entry names which should never be synchronized with --sync-values. e.g. replicaCount")
+	cmd.Flags().StringVarP(&o.PolicyFile, "policy-file", "", "", "the file containing the version policies of the charts. Defaults to "+DefaultPolicyFile+" in the target repository if it exists")
 
 	o.BaseOptions.AddBaseFlags(cmd)
 	o.EnvironmentPullRequestOptions.ScmClientFactory.AddFlags(cmd)
@@ -110,7 +173,8 @@ func NewCmdEnvironmentSync() (*cobra.Command, *Options) {
 	cmd.Flags().StringVarP(&o.CommitMessage, "commit-message", "", "", "the commit message")
 
 	o.Source.AddFlags(cmd, "source")
-	o.Target.AddFlags(cmd, "target")
+	o.Target.AddRepositoryFlags(cmd, "target")
+	cmd.Flags().StringSliceVarP(&o.TargetEnvs, "target-env", "", nil, "the environment names for the target. Can be specified multiple times to create a Pull Request for each environment. Use 'all' with --source-env for all the environments after the source environment in promotion order")
 
 	return cmd, o
 }
@@ -121,20 +185,17 @@ func (o *Options) Validate() error {
 	if err != nil {
 		return fmt.Errorf("failed to validate base options: %w", err)
 	}
-	if o.Input == nil {
-		o.Input = inputfactory.NewInput(&o.BaseOptions)
-	}
-	o.JXClient, o.Namespace, err = jxclient.LazyCreateJXClientAndNamespace(o.JXClient, o.Namespace)
+	err = o.ValuesFilter.Validate()
 	if err != nil {
-		return fmt.Errorf("failed to create JX client: %w", err)
+		return fmt.Errorf("failed to validate values filter: %w", err)
 	}
-	o.EnvMap, o.EnvNames, err = jxenv.GetOrderedEnvironments(o.JXClient, o.Namespace)
+	err = o.SetFilter.Validate()
 	if err != nil {
-		return fmt.Errorf("failed to load environments: %w", err)
+		return fmt.Errorf("failed to validate set filter: %w", err)
+	}
+	if o.Input == nil {
+		o.Input = inputfactory.NewInput(&o.BaseOptions)
 	}
-	// lets remove the dev env name as we don't promote to/from it
-	o.EnvNames = stringhelpers.RemoveStringFromSlice(o.EnvNames, "dev")
-
 	// lazy create git
 	o.EnvironmentPullRequestOptions.Git()
 	return nil
@@ -152,20 +213,56 @@ func (o *Options) Run() error {
 		return fmt.Errorf("failed to choose environments: %w", err)
 	}
 
-	gitURL := o.Target.GitCloneURL
-	if gitURL == "" {
-		return fmt.Errorf("no target git clone URL")
+	targets, err := o.TargetEnvironments()
+	if err != nil {
+		return fmt.Errorf("failed to find target environments: %w", err)
 	}
 
-	o.SourceDir = ""
-	sourceGitURL := o.Source.GitCloneURL
-	if sourceGitURL != "" && gitURL != sourceGitURL {
-		o.SourceDir, err = gitclient.CloneToDir(o.Git(), sourceGitURL, "")
+	// lets reuse the same source clone for all the targets
+	sourceDir := ""
+	versionStreamDir := o.VersionStreamDir
+	prefixes := o.Prefixes
+	var failed []string
+	for _, target := range targets {
+		o.Target = *target
+		gitURL := o.Target.GitCloneURL
+		if gitURL == "" {
+			log.Logger().Errorf("no target git clone URL for %s", o.Target.EnvironmentName)
+			failed = append(failed, o.Target.EnvironmentName)
+			continue
+		}
+
+		o.SourceDir = ""
+		sourceGitURL := o.Source.GitCloneURL
+		if sourceGitURL != "" && gitURL != sourceGitURL {
+			if sourceDir == "" {
+				sourceDir, err = gitclient.CloneToDir(o.Git(), sourceGitURL, "")
+				if err != nil {
+					return fmt.Errorf("failed to clone source cluster %s: %w", sourceGitURL, err)
+				}
+			}
+			o.SourceDir = sourceDir
+		}
+		o.VersionStreamDir = versionStreamDir
+		o.Prefixes = prefixes
+
+		err = o.createPullRequest(gitURL)
 		if err != nil {
-			return fmt.Errorf("failed to clone source cluster %s: %w", sourceGitURL, err)
+			name := o.Target.EnvironmentName
+			if name == "" {
+				name = gitURL
+			}
+			log.Logger().Errorf("failed to sync %s: %s", name, err.Error())
+			failed = append(failed, name)
 		}
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to sync targets: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
 
+func (o *Options) createPullRequest(gitURL string) error {
 	// lets clear the branch name so we create a new one each time in a loop
 	o.BranchName = ""
 
@@ -173,12 +270,23 @@ func (o *Options) Run() error {
 		o.CommitTitle = "chore: sync versions"
 	}
 
+	// lets restore the PR body so that each target gets its own changes
+	commitMessage := o.CommitMessage
+	defer func() {
+		o.CommitMessage = commitMessage
+	}()
+
 	o.Function = func() error {
 		dir := o.OutDir
-		return o.SyncVersions(o.SourceDir, dir)
+		o.Changes = nil
+		o.Pruned = nil
+		o.PolicySkipped = nil
+		err := o.SyncVersions(o.SourceDir, dir)
+		o.CommitMessage = o.PullRequestBody(commitMessage)
+		return err
 	}
 
-	_, err = o.EnvironmentPullRequestOptions.Create(gitURL, "", o.Labels, o.AutoMerge)
+	_, err := o.EnvironmentPullRequestOptions.Create(gitURL, "", o.Labels, o.AutoMerge)
 	if err != nil {
 		return fmt.Errorf("failed to create Pull Request on repository %s: %w", gitURL, err)
 	}
@@ -217,11 +325,24 @@ func (o *Options) SyncVersions(sourceDir, targetDir string) error {
 		}
 	}
 
+	o.Policy, err = o.LoadPolicy(targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to load sync policy: %w", err)
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	targetVersions, err := findTargetVersions(targetHelmfiles)
+	if err != nil {
+		return fmt.Errorf("failed to find target versions: %w", err)
+	}
+
 	editor, err := helmfiles.NewEditor(targetDir, targetHelmfiles)
 	if err != nil {
 		return fmt.Errorf("failed to create helmfile editor: %w", err)
 	}
-	err = o.syncHelmfileVersions(sourceHelmfiles, editor)
+	synced := map[string]*syncedRelease{}
+	err = o.syncHelmfileVersions(sourceHelmfiles, targetVersions, editor, synced)
 	if err != nil {
 		return fmt.Errorf("failed to sync versions: %w", err)
 	}
@@ -230,10 +351,26 @@ func (o *Options) SyncVersions(sourceDir, targetDir string) error {
 	if err != nil {
 		return fmt.Errorf("failed to save modified files: %w", err)
 	}
+	if o.Prune {
+		sourceKeys, err := o.findSourceKeys(sourceHelmfiles)
+		if err != nil {
+			return fmt.Errorf("failed to find source charts: %w", err)
+		}
+		err = o.pruneReleases(targetDir, sourceKeys)
+		if err != nil {
+			return fmt.Errorf("failed to prune releases: %w", err)
+		}
+	}
+	if o.SyncValues {
+		err = o.syncValues(targetDir, synced)
+		if err != nil {
+			return fmt.Errorf("failed to sync values: %w", err)
+		}
+	}
 	return nil
 }
 
-func (o *Options) syncHelmfileVersions(sourceHelmfiles []helmfiles.Helmfile, editor *helmfiles.Editor) error {
+func (o *Options) syncHelmfileVersions(sourceHelmfiles []helmfiles.Helmfile, targetVersions map[string]string, editor *helmfiles.Editor, synced map[string]*syncedRelease) error {
 	for i := range sourceHelmfiles {
 		src := &sourceHelmfiles[i]
 		path := src.Filepath
@@ -255,16 +392,76 @@ func (o *Options) syncHelmfileVersions(sourceHelmfiles []helmfiles.Helmfile, edi
 				if o.Target.Namespace != "" {
 					details.Namespace = o.Target.Namespace
 				}
+				reason, err := o.PolicyReason(details, path, targetVersions[chartKey(details.Namespace, details.Chart)])
+				if err != nil {
+					return fmt.Errorf("failed to check the policy of chart %s: %w", details.String(), err)
+				}
+				if reason != "" {
+					log.Logger().Infof("not syncing chart %s version %s: %s", details.Chart, details.Version, reason)
+					o.PolicySkipped = append(o.PolicySkipped, fmt.Sprintf("%s %s: %s", details.Chart, details.Version, reason))
+					continue
+				}
 				err = editor.AddChart(details)
 				if err != nil {
 					return fmt.Errorf("failed to add chart %s: %w", details.String(), err)
 				}
+				key := chartKey(details.Namespace, details.Chart)
+				synced[key] = &syncedRelease{Helmfile: path, Release: rel}
+				o.addChange(details, rel.Name, targetVersions[key])
 			}
 		}
 	}
 	return nil
 }
 
+// addChange records the change of the chart version for the Pull Request body
+func (o *Options) addChange(details *helmfiles.ChartDetails, name, from string) {
+	if from == details.Version || (from == "" && o.UpdateOnly) {
+		return
+	}
+	if name == "" {
+		_, name = helmfiles.SpitChartName(details.Chart)
+	}
+	gitURL := changelog.ChartGitURL(o.VersionStreamDir, details.Chart)
+	o.Changes = append(o.Changes, &changelog.Change{
+		Namespace: details.Namespace,
+		Name:      name,
+		Chart:     details.Chart,
+		From:      from,
+		To:        details.Version,
+		GitURL:    gitURL,
+		Tag:       o.resolveTag(gitURL, details.Version),
+	})
+}
+
+// resolveTag returns the git tag of the chart version in its source repository so that its release notes can be linked
+func (o *Options) resolveTag(gitURL, version string) string {
+	if !changelog.HasReleaseNotes(gitURL) {
+		return ""
+	}
+	key := gitURL + "@" + version
+	if tag, ok := o.Tags[key]; ok {
+		return tag
+	}
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.QuietCommandRunner
+	}
+	tag, err := changelog.ResolveTag(o.CommandRunner, gitURL, version)
+	if err != nil {
+		log.Logger().Debugf("failed to resolve the git tag of version %s in %s: %s", version, gitURL, err.Error())
+	}
+	if o.Tags == nil {
+		o.Tags = map[string]string{}
+	}
+	o.Tags[key] = tag
+	return tag
+}
+
+// PullRequestBody returns the Pull Request body describing the changed versions and removed releases after the message
+func (o *Options) PullRequestBody(message string) string {
+	return changelog.JoinSections(message, changelog.Markdown(o.Changes), o.prunedMarkdown())
+}
+
 // Matches return true if the chart details matches the filters
 func (o *ChartFilter) Matches(details *helmfiles.ChartDetails) bool {
 	if len(o.Namespaces) > 0 {
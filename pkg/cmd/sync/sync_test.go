@@ -1,13 +1,23 @@
 package sync_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
 	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles/testhelmfile"
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/sync"
+	v1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/versionstream"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -52,6 +62,17 @@ func TestSync(t *testing.T) {
 			o.UpdateOnly = true
 			o.Source.Namespace = "jx-staging"
 			o.Target.Namespace = "jx-production"
+		case "prune":
+			o.Prune = true
+			o.PruneKeep = []string{"monitoring"}
+			o.Source.Namespace = "jx-staging"
+			o.Target.Namespace = "jx-production"
+		case "values":
+			o.SyncValues = true
+			o.ValuesFilter.Excludes = []string{"**/secrets.yaml"}
+			o.SetFilter.Excludes = []string{"replicaCount"}
+			o.Source.Namespace = "jx-staging"
+			o.Target.Namespace = "jx-production"
 		}
 
 		srcDir := filepath.Join(dir, "source")
@@ -72,5 +93,290 @@ func TestSync(t *testing.T) {
 		require.NoError(t, err, "failed to process test %s", name)
 
 		testhelmfile.AssertHelmfiles(t, expectedDir, outDir, generateTestOutput)
+		if o.Prune {
+			assert.Equal(t, []string{"dev/oldapp in namespace jx-production"}, o.Pruned, "pruned releases for %s", name)
+		}
+		assertValuesFiles(t, expectedDir, outDir)
+	}
+}
+
+// assertValuesFiles asserts the files other than the helmfiles in the expected dir match the output dir
+func assertValuesFiles(t *testing.T, expectedDir, outDir string) {
+	err := filepath.Walk(expectedDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() == "helmfile.yaml" {
+			return err
+		}
+		rel, err := filepath.Rel(expectedDir, path)
+		require.NoError(t, err, "failed to get relative path of %s", path)
+		expected, err := os.ReadFile(path)
+		require.NoError(t, err, "failed to load %s", path)
+		actualFile := filepath.Join(outDir, rel)
+		actual, err := os.ReadFile(actualFile)
+		require.NoError(t, err, "failed to load %s", actualFile)
+		assert.Equal(t, string(expected), string(actual), "file %s", rel)
+		return nil
+	})
+	require.NoError(t, err, "failed to walk %s", expectedDir)
+}
+
+func TestTargetEnvironments(t *testing.T) {
+	envMap := map[string]*v1.Environment{}
+	for _, name := range []string{"dev", "staging", "canary", "production"} {
+		env := &v1.Environment{}
+		env.Name = name
+		env.Spec.Namespace = "jx-" + name
+		if name == "production" {
+			env.Spec.Source.URL = "https://github.com/myorg/production.git"
+		}
+		envMap[name] = env
+	}
+	envMap["dev"].Spec.Source.URL = "https://github.com/myorg/dev.git"
+
+	testCases := []struct {
+		name       string
+		targetEnvs []string
+		expected   []string
+	}{
+		{
+			name:       "all",
+			targetEnvs: []string{sync.AllEnvironments},
+			expected:   []string{"canary:jx-canary:https://github.com/myorg/dev.git", "production:jx-production:https://github.com/myorg/production.git"},
+		},
+		{
+			name:       "list",
+			targetEnvs: []string{"production", "canary", "production"},
+			expected:   []string{"production:jx-production:https://github.com/myorg/production.git", "canary:jx-canary:https://github.com/myorg/dev.git"},
+		},
+		{
+			name:     "single",
+			expected: []string{"canary:jx-canary:https://github.com/myorg/dev.git"},
+		},
+	}
+	for _, tc := range testCases {
+		_, o := sync.NewCmdEnvironmentSync()
+		o.EnvMap = envMap
+		o.EnvNames = []string{"staging", "canary", "production"}
+		o.Source.EnvironmentName = "staging"
+		o.TargetEnvs = tc.targetEnvs
+		if len(tc.targetEnvs) == 0 {
+			o.Target.EnvironmentName = "canary"
+		}
+
+		err := o.ChooseEnvironments()
+		require.NoError(t, err, "failed to choose environments for %s", tc.name)
+		require.Equal(t, "jx-staging", o.Source.Namespace, "source namespace for %s", tc.name)
+
+		targets, err := o.TargetEnvironments()
+		require.NoError(t, err, "failed to find target environments for %s", tc.name)
+
+		var got []string
+		for _, target := range targets {
+			got = append(got, target.EnvironmentName+":"+target.Namespace+":"+target.GitCloneURL)
+		}
+		assert.Equal(t, tc.expected, got, "targets for %s", tc.name)
+	}
+
+	_, o := sync.NewCmdEnvironmentSync()
+	o.EnvMap = envMap
+	o.EnvNames = []string{"staging", "canary", "production"}
+	o.Source.EnvironmentName = "production"
+	o.TargetEnvs = []string{sync.AllEnvironments}
+	err := o.ChooseEnvironments()
+	require.NoError(t, err, "failed to choose environments")
+	_, err = o.TargetEnvironments()
+	require.Error(t, err, "should have no environments after production")
+
+	_, o = sync.NewCmdEnvironmentSync()
+	o.EnvMap = envMap
+	o.EnvNames = []string{"staging", "canary", "production"}
+	o.Source.Namespace = "jx-staging"
+	o.TargetEnvs = []string{sync.AllEnvironments}
+	_, err = o.TargetEnvironments()
+	require.Error(t, err, "should require a source environment for all")
+	assert.Contains(t, err.Error(), "--source-env")
+}
+
+func TestSyncWithoutCluster(t *testing.T) {
+	// lets make sure we never talk to a cluster
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "does-not-exist"))
+
+	tmpDir := t.TempDir()
+	srcRepo := filepath.Join(tmpDir, "source")
+	targetRepo := filepath.Join(tmpDir, "target")
+	now := time.Now()
+
+	writeHelmfile(t, filepath.Join(srcRepo, "helmfile.yaml"), "helmfiles:\n- path: helmfiles/jx-staging/helmfile.yaml\n")
+	writeHelmfile(t, filepath.Join(srcRepo, "helmfiles", "jx-staging", "helmfile.yaml"), stagingHelmfile(map[string]string{"myapp": "1.2.0"}))
+	writeHelmfile(t, filepath.Join(srcRepo, "versionStream", "charts", "repositories.yml"), "repositories:\n- prefix: dev\n  urls:\n  - http://chartmuseum.example.com/\n")
+	writeHelmfile(t, filepath.Join(srcRepo, "versionStream", "charts", "dev", "myapp", "defaults.yaml"), "gitUrl: https://github.com/myorg/myapp\n")
+	gitCommit(t, srcRepo, now, "init", "-q")
+
+	writeHelmfile(t, filepath.Join(targetRepo, "helmfile.yaml"), "helmfiles:\n- path: helmfiles/jx-production/helmfile.yaml\n")
+	writeHelmfile(t, filepath.Join(targetRepo, "helmfiles", "jx-production", "helmfile.yaml"), strings.ReplaceAll(stagingHelmfile(map[string]string{"myapp": "1.0.0"}), "jx-staging", "jx-production"))
+	gitCommit(t, targetRepo, now, "init", "-q")
+
+	_, o := sync.NewCmdEnvironmentSync()
+	o.BaseOptions.BatchMode = true
+	o.Source.GitCloneURL = srcRepo
+	o.Source.Namespace = "jx-staging"
+	o.Target.GitCloneURL = targetRepo
+	o.Target.Namespace = "jx-production"
+
+	// lets resolve the git tags without accessing the chart repository
+	var lsRemotes []string
+	o.CommandRunner = func(c *cmdrunner.Command) (string, error) {
+		if len(c.Args) > 0 && c.Args[0] == "ls-remote" {
+			lsRemotes = append(lsRemotes, c.CLI())
+			return "1234567890abcdef\trefs/tags/v1.2.0\n", nil
+		}
+		return cmdrunner.QuietCommandRunner(c)
+	}
+
+	err := o.Validate()
+	require.NoError(t, err, "failed to validate")
+	err = o.ChooseEnvironments()
+	require.NoError(t, err, "failed to choose environments")
+	targets, err := o.TargetEnvironments()
+	require.NoError(t, err, "failed to find target environments")
+	require.Len(t, targets, 1)
+	assert.Equal(t, targetRepo, targets[0].GitCloneURL)
+	assert.Nil(t, o.JXClient, "should not have created a JX client")
+	assert.Nil(t, o.EnvMap, "should not have loaded the environments")
+
+	sourceDir, err := gitclient.CloneToDir(o.Git(), srcRepo, "")
+	require.NoError(t, err, "failed to clone %s", srcRepo)
+	targetDir, err := gitclient.CloneToDir(o.Git(), targetRepo, "")
+	require.NoError(t, err, "failed to clone %s", targetRepo)
+
+	err = o.SyncVersions(sourceDir, targetDir)
+	require.NoError(t, err, "failed to sync versions")
+
+	helmStates, err := helmfiles.LoadHelmfile(filepath.Join(targetDir, "helmfiles", "jx-production", "helmfile.yaml"))
+	require.NoError(t, err, "failed to load target helmfile")
+	require.Len(t, helmStates, 1)
+	require.Len(t, helmStates[0].Releases, 1)
+	assert.Equal(t, "1.2.0", helmStates[0].Releases[0].Version, "target version")
+
+	body := o.PullRequestBody("my message")
+	assert.Contains(t, body, "my message\n\nSynchronized the following versions:\n\n### namespace `jx-production`", "body %s", body)
+	assert.Contains(t, body, "| myapp | dev/myapp | 1.0.0 → 1.2.0 | [source](https://github.com/myorg/myapp) [release notes](https://github.com/myorg/myapp/releases/tag/v1.2.0) |", "body %s", body)
+	require.Len(t, lsRemotes, 1, "should resolve the git tag once")
+	assert.Contains(t, lsRemotes[0], "https://github.com/myorg/myapp refs/tags/1.2.0 refs/tags/v1.2.0")
+}
+
+func TestSyncPolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "source")
+	targetDir := filepath.Join(tmpDir, "target")
+	now := time.Now()
+
+	sourceHelmfile := filepath.Join(srcDir, "helmfiles", "jx-staging", "helmfile.yaml")
+	writeHelmfile(t, filepath.Join(srcDir, "helmfile.yaml"), "helmfiles:\n- path: helmfiles/jx-staging/helmfile.yaml\n")
+	writeHelmfile(t, sourceHelmfile, stagingHelmfile(map[string]string{
+		"old-patch": "1.0.1",
+		"fresh":     "2.0.0",
+		"big-jump":  "3.0.0",
+		"rc":        "1.1.0-rc.1",
+		"pinned":    "5.0.1",
+		"exempt":    "1.0.0",
+	}))
+	gitCommit(t, srcDir, now.Add(-48*time.Hour), "init", "-q")
+	writeHelmfile(t, sourceHelmfile, stagingHelmfile(map[string]string{
+		"old-patch": "1.0.1",
+		"fresh":     "2.0.1",
+		"big-jump":  "3.0.0",
+		"rc":        "1.1.0-rc.1",
+		"pinned":    "5.0.1",
+		"exempt":    "9.0.0",
+	}))
+	gitCommit(t, srcDir, now.Add(-time.Hour))
+
+	writeHelmfile(t, filepath.Join(targetDir, "helmfile.yaml"), "helmfiles:\n- path: helmfiles/jx-staging/helmfile.yaml\n")
+	writeHelmfile(t, filepath.Join(targetDir, "helmfiles", "jx-staging", "helmfile.yaml"), stagingHelmfile(map[string]string{
+		"old-patch": "1.0.0",
+		"fresh":     "2.0.0",
+		"big-jump":  "1.0.0",
+		"rc":        "1.0.0",
+		"pinned":    "5.0.0",
+		"exempt":    "1.0.0",
+	}))
+	writeHelmfile(t, filepath.Join(targetDir, ".jx", "sync-policy.yaml"), `spec:
+  default:
+    minAge: 24h
+    maxJump: minor
+    denyPrerelease: true
+  charts:
+  - chart: pinned
+    pinned: true
+  - chart: dev/exempt
+`)
+
+	_, o := sync.NewCmdEnvironmentSync()
+	o.CommandRunner = cmdrunner.QuietCommandRunner
+	o.Prefixes = &versionstream.RepositoryPrefixes{}
+	err := o.SyncVersions(srcDir, targetDir)
+	require.NoError(t, err, "failed to sync versions")
+
+	helmStates, err := helmfiles.LoadHelmfile(filepath.Join(targetDir, "helmfiles", "jx-staging", "helmfile.yaml"))
+	require.NoError(t, err, "failed to load target helmfile")
+	versions := map[string]string{}
+	for _, helmState := range helmStates {
+		for _, rel := range helmState.Releases {
+			versions[rel.Name] = rel.Version
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"old-patch": "1.0.1",
+		"fresh":     "2.0.0",
+		"big-jump":  "1.0.0",
+		"rc":        "1.0.0",
+		"pinned":    "5.0.0",
+		"exempt":    "9.0.0",
+	}, versions)
+	assert.Len(t, o.PolicySkipped, 4, "skipped charts: %v", o.PolicySkipped)
+}
+
+func stagingHelmfile(versions map[string]string) string {
+	var names []string
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf := strings.Builder{}
+	buf.WriteString("namespace: jx-staging\nrepositories:\n- name: dev\n  url: http://chartmuseum.example.com/\nreleases:\n")
+	for _, name := range names {
+		buf.WriteString(fmt.Sprintf("- chart: dev/%s\n  version: %s\n  name: %s\n", name, versions[name], name))
+	}
+	return buf.String()
+}
+
+func writeHelmfile(t *testing.T, path, text string) {
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	require.NoError(t, err, "failed to create dir for %s", path)
+	err = os.WriteFile(path, []byte(text), files.DefaultFileWritePermissions)
+	require.NoError(t, err, "failed to save %s", path)
+}
+
+func gitCommit(t *testing.T, dir string, date time.Time, initArgs ...string) {
+	var commands [][]string
+	if len(initArgs) > 0 {
+		commands = append(commands, initArgs)
+	}
+	commands = append(commands,
+		[]string{"add", "."},
+		[]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "change versions"},
+	)
+	for _, args := range commands {
+		c := &cmdrunner.Command{
+			Dir:  dir,
+			Name: "git",
+			Args: args,
+			Env: map[string]string{
+				"GIT_AUTHOR_DATE":    date.Format(time.RFC3339),
+				"GIT_COMMITTER_DATE": date.Format(time.RFC3339),
+			},
+		}
+		_, err := cmdrunner.QuietCommandRunner(c)
+		require.NoError(t, err, "failed to run git %v", args)
 	}
 }
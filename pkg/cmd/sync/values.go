@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/helmfile/helmfile/pkg/state"
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// JXValuesFile the values file generated for each environment which is never synchronized
+const JXValuesFile = "jx-values.yaml"
+
+// syncedRelease a source release whose version has been synchronized to the target
+type syncedRelease struct {
+	Helmfile string
+	Release  *state.ReleaseSpec
+}
+
+// syncValues synchronizes the values files and set entries of the synchronized source releases to the target releases
+func (o *Options) syncValues(targetDir string, synced map[string]*syncedRelease) error {
+	targetHelmfiles, err := helmfiles.GatherHelmfiles(o.Target.Helmfile, targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to gather target helmfiles from %s: %w", targetDir, err)
+	}
+	for i := range targetHelmfiles {
+		path := targetHelmfiles[i].Filepath
+		helmStates, err := helmfiles.LoadHelmfile(path)
+		if err != nil {
+			return fmt.Errorf("failed to load helmfile %s: %w", path, err)
+		}
+		modified := false
+		for _, helmState := range helmStates {
+			for j := range helmState.Releases {
+				rel := &helmState.Releases[j]
+				ns := rel.Namespace
+				if ns == "" {
+					ns = helmState.OverrideNamespace
+				}
+				src := synced[chartKey(ns, rel.Chart)]
+				if src == nil {
+					continue
+				}
+				flag, err := o.syncReleaseValues(src, path, rel, targetDir)
+				if err != nil {
+					return fmt.Errorf("failed to sync values of release %s: %w", rel.Name, err)
+				}
+				modified = modified || flag
+			}
+		}
+		if modified {
+			err = helmfiles.SaveHelmfile(path, helmStates)
+			if err != nil {
+				return fmt.Errorf("failed to save helmfile %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// syncReleaseValues copies the values files and set entries of the source release to the target release returning
+// true if the target release was modified. Only values files inside the directory of the source helmfile are copied
+// so that shared files such as those in the version stream and the generated jx-values.yaml of each environment are
+// never copied. Values files and set entries removed from the source release are left in the target release
+func (o *Options) syncReleaseValues(src *syncedRelease, targetHelmfile string, target *state.ReleaseSpec, targetDir string) (bool, error) {
+	modified := false
+	for _, v := range src.Release.Values {
+		name, ok := v.(string)
+		if !ok || strings.Contains(name, "{{") || filepath.IsAbs(name) || filepath.Base(name) == JXValuesFile {
+			continue
+		}
+		clean := filepath.Clean(name)
+		if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			continue
+		}
+		sourcePath := filepath.Join(filepath.Dir(src.Helmfile), name)
+		targetPath := filepath.Join(filepath.Dir(targetHelmfile), name)
+		rel, err := filepath.Rel(targetDir, targetPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to get relative path of %s: %w", targetPath, err)
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(rel, "..") || strings.HasPrefix(rel, "versionStream/") || !o.ValuesFilter.Matches(rel) {
+			continue
+		}
+		exists, err := files.FileExists(sourcePath)
+		if err != nil {
+			return false, fmt.Errorf("failed to check if file exists %s: %w", sourcePath, err)
+		}
+		if !exists {
+			continue
+		}
+		err = copyIfChanged(sourcePath, targetPath)
+		if err != nil {
+			return false, err
+		}
+		if !containsValue(target.Values, name) {
+			target.Values = append(target.Values, name)
+			modified = true
+		}
+	}
+
+	for _, sv := range src.Release.SetValues {
+		if sv.Name == "" || !o.SetFilter.Matches(sv.Name) {
+			continue
+		}
+		found := false
+		for k := range target.SetValues {
+			if target.SetValues[k].Name == sv.Name {
+				found = true
+				if !reflect.DeepEqual(target.SetValues[k], sv) {
+					target.SetValues[k] = sv
+					modified = true
+				}
+				break
+			}
+		}
+		if !found {
+			target.SetValues = append(target.SetValues, sv)
+			modified = true
+		}
+	}
+	return modified, nil
+}
+
+// copyIfChanged copies the source file to the target file if its contents are different
+func copyIfChanged(sourcePath, targetPath string) error {
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", sourcePath, err)
+	}
+	exists, err := files.FileExists(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if file exists %s: %w", targetPath, err)
+	}
+	if exists {
+		current, err := os.ReadFile(targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", targetPath, err)
+		}
+		if bytes.Equal(current, data) {
+			return nil
+		}
+	}
+	err = os.MkdirAll(filepath.Dir(targetPath), files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create dir for %s: %w", targetPath, err)
+	}
+	err = os.WriteFile(targetPath, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", targetPath, err)
+	}
+	log.Logger().Infof("synchronized values file %s", targetPath)
+	return nil
+}
+
+func containsValue(values []any, name string) bool {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"strings"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x/go-scm/scm"
+)
+
+// ReleaseEvent a release or tag of a repository received from a webhook
+type ReleaseEvent struct {
+	GitURL   string
+	CloneURL string
+	Tag      string
+	SHA      string
+}
+
+// Version returns the version of the release without any v prefix
+func (e *ReleaseEvent) Version() string {
+	return strings.TrimPrefix(e.Tag, "v")
+}
+
+// Application returns the owner/name of the released repository
+func (e *ReleaseEvent) Application() string {
+	parts := strings.Split(e.GitURL, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// key returns the key used to avoid processing the same release twice as both release and tag events are usually sent
+func (e *ReleaseEvent) key() string {
+	return e.GitURL + "@" + e.Version()
+}
+
+// ReleaseEventFromWebhook returns the release event for a published release, created tag or pushed tag
+// or nil if the webhook is not a release
+func ReleaseEventFromWebhook(hook scm.Webhook) *ReleaseEvent {
+	switch h := hook.(type) {
+	case *scm.ReleaseHook:
+		if h.Action == scm.ActionDelete || h.Release.Draft || h.Release.Prerelease {
+			return nil
+		}
+		return newReleaseEvent(&h.Repo, h.Release.Tag, "")
+	case *scm.TagHook:
+		if h.Action != scm.ActionCreate {
+			return nil
+		}
+		return newReleaseEvent(&h.Repo, h.Ref.Name, h.Ref.Sha)
+	case *scm.PushHook:
+		if h.Deleted || !strings.HasPrefix(h.Ref, "refs/tags/") {
+			return nil
+		}
+		return newReleaseEvent(&h.Repo, strings.TrimPrefix(h.Ref, "refs/tags/"), h.After)
+	default:
+		return nil
+	}
+}
+
+func newReleaseEvent(repo *scm.Repository, tag, sha string) *ReleaseEvent {
+	gitURL := repo.Link
+	if gitURL == "" {
+		gitURL = repo.Clone
+	}
+	if gitURL == "" || tag == "" {
+		return nil
+	}
+	cloneURL := repo.Clone
+	if cloneURL == "" {
+		cloneURL = gitURL
+	}
+	return &ReleaseEvent{
+		GitURL:   gitops.TrimGitURLSuffix(gitURL),
+		CloneURL: cloneURL,
+		Tag:      tag,
+		SHA:      sha,
+	}
+}
@@ -0,0 +1,410 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/client/clientset/versioned"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/updatebotclient"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/factory"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/gitclient/cli"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/scmhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Runs a webhook server which creates Pull Requests on the downstream repositories when a repository is released or tagged
+
+		Release and tag push events from GitHub, GitLab, Bitbucket and Gitea are supported. The released repository is
+		matched against the sourceURLs of the central UpdateConfig files or resources.
+
+		Webhooks must be signed with the --hmac-token secret unless --insecure-skip-hmac is specified.
+
+		If no central configuration matches and --repo-config is specified then the .jx/updatebot.yaml file in the released
+		repository is used. As this runs the commands in that file with the git token of the bot only the repositories on the
+		--git-server owned by one of the --repo-config-owner values are used.
+`)
+
+	cmdExample = templates.Examples(`
+		# run the webhook server using a central configuration file
+		jx updatebot webhook --config-file updatebot.yaml
+
+		# run the webhook server using the UpdateConfig resources in the jx namespace
+		jx updatebot webhook --config-resources --config-namespace jx
+
+		# run the webhook server using the configuration in the released repositories of the given owner
+		jx updatebot webhook --repo-config --git-server https://github.com --repo-config-owner myorg
+`)
+)
+
+const (
+	// processedCacheSize the maximum number of releases remembered to avoid processing a release twice
+	processedCacheSize = 1000
+
+	// processedTTL how long a processed release is remembered
+	processedTTL = 24 * time.Hour
+)
+
+// Options the options for the command
+type Options struct {
+	Port             int
+	Path             string
+	HMACToken        string
+	InsecureSkipHMAC bool
+	ConfigFiles      []string
+	ConfigNamespace  string
+	ConfigResources  bool
+	RepoConfig       bool
+	RepoConfigOwners []string
+	AutoMerge        bool
+	UpdatebotClient  versioned.Interface
+	ScmClientFactory scmhelpers.Factory
+	CommandRunner    cmdrunner.CommandRunner
+	GitClient        gitclient.Interface
+
+	// RunPullRequest runs the pr command for a release. Defaults to running the command
+	RunPullRequest func(po *pr.Options) error
+
+	processed *cache.LRUExpireCache
+	lock      sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// NewCmdWebhook creates a command object for the command
+func NewCmdWebhook() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "webhook",
+		Short:   "Runs a webhook server which creates Pull Requests on the downstream repositories when a repository is released or tagged",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().IntVarP(&o.Port, "port", "p", 8080, "the port to listen on")
+	cmd.Flags().StringVarP(&o.Path, "path", "", "/hook", "the path to receive webhooks on")
+	cmd.Flags().StringVarP(&o.HMACToken, "hmac-token", "", os.Getenv("HMAC_TOKEN"), "the secret used to validate the webhooks. Defaults to $HMAC_TOKEN")
+	cmd.Flags().StringArrayVarP(&o.ConfigFiles, "config-file", "c", nil, "the central UpdateConfig files whose sourceURLs are matched against the released repository")
+	cmd.Flags().BoolVarP(&o.ConfigResources, "config-resources", "", false, "matches the released repository against the sourceURLs of the UpdateConfig resources in the cluster")
+	cmd.Flags().StringVarP(&o.ConfigNamespace, "config-namespace", "", "", "the namespace of the UpdateConfig resources. Defaults to the current namespace")
+	cmd.Flags().BoolVarP(&o.InsecureSkipHMAC, "insecure-skip-hmac", "", false, "allows running without a --hmac-token so that unsigned webhooks are accepted. Only use this for testing")
+	cmd.Flags().BoolVarP(&o.RepoConfig, "repo-config", "", false, "uses the .jx/updatebot.yaml file in the released repository if no central configuration matches. Requires --git-server and --repo-config-owner")
+	cmd.Flags().StringArrayVarP(&o.RepoConfigOwners, "repo-config-owner", "", nil, "the owners of the repositories on the --git-server whose .jx/updatebot.yaml file can be used with --repo-config")
+	cmd.Flags().BoolVarP(&o.AutoMerge, "auto-merge", "", true, "should we automatically merge if the PR pipeline is green")
+	o.ScmClientFactory.AddFlags(cmd)
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	err := o.Validate()
+	if err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(o.Path, o)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", o.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		err := server.Shutdown(context.Background())
+		if err != nil {
+			log.Logger().Warnf("failed to shutdown server: %s", err.Error())
+		}
+	}()
+
+	log.Logger().Infof("listening for webhooks on port %s at path %s", info(o.Port), info(o.Path))
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve webhooks: %w", err)
+	}
+	o.Wait()
+	return nil
+}
+
+// Validate validates the options
+func (o *Options) Validate() error {
+	if o.HMACToken == "" {
+		if !o.InsecureSkipHMAC {
+			return options.MissingOption("hmac-token")
+		}
+		log.Logger().Warnf("no --hmac-token specified so webhooks will not be validated")
+	}
+	if o.RepoConfig {
+		if o.ScmClientFactory.GitServerURL == "" {
+			return options.MissingOption("git-server")
+		}
+		if len(o.RepoConfigOwners) == 0 {
+			return options.MissingOption("repo-config-owner")
+		}
+	}
+	if o.ConfigResources {
+		var err error
+		o.UpdatebotClient, o.ConfigNamespace, err = updatebotclient.LazyCreateUpdatebotClientAndNamespace(o.UpdatebotClient, o.ConfigNamespace)
+		if err != nil {
+			return fmt.Errorf("failed to create updatebot client: %w", err)
+		}
+	}
+	if o.CommandRunner == nil {
+		o.CommandRunner = cmdrunner.DefaultCommandRunner
+	}
+	if o.GitClient == nil {
+		o.GitClient = cli.NewCLIClient("", o.CommandRunner)
+	}
+	if o.RunPullRequest == nil {
+		o.RunPullRequest = func(po *pr.Options) error {
+			return po.Run()
+		}
+	}
+	o.processed = cache.NewLRUExpireCache(processedCacheSize)
+	return nil
+}
+
+// ServeHTTP parses the webhook and processes any release in the background
+func (o *Options) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	kind := o.ScmClientFactory.GitKind
+	if kind == "" {
+		kind = GitKindFromHeaders(r.Header)
+	}
+	service, err := factory.NewWebHookService(kind)
+	if err != nil || service == nil {
+		http.Error(w, fmt.Sprintf("unsupported git kind %s", kind), http.StatusBadRequest)
+		return
+	}
+
+	hook, err := service.Parse(r, func(scm.Webhook) (string, error) {
+		return o.HMACToken, nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, scm.ErrSignatureInvalid):
+			http.Error(w, "invalid webhook signature", http.StatusUnauthorized)
+		case scm.IsUnknownWebhook(err):
+			w.WriteHeader(http.StatusOK)
+		default:
+			log.Logger().Warnf("failed to parse webhook: %s", err.Error())
+			http.Error(w, "failed to parse webhook", http.StatusBadRequest)
+		}
+		return
+	}
+
+	event := ReleaseEventFromWebhook(hook)
+	if event == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		err := o.ProcessRelease(event)
+		if err != nil {
+			log.Logger().Errorf("failed to process release %s of %s: %s", event.Tag, event.GitURL, err.Error())
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Wait waits for any releases being processed
+func (o *Options) Wait() {
+	o.wg.Wait()
+}
+
+// ProcessRelease creates the Pull Requests for the release unless it is already being or has been processed
+func (o *Options) ProcessRelease(event *ReleaseEvent) error {
+	key := event.key()
+	o.lock.Lock()
+	if _, ok := o.processed.Get(key); ok {
+		o.lock.Unlock()
+		return nil
+	}
+	o.processed.Add(key, true, processedTTL)
+	o.lock.Unlock()
+
+	err := o.processRelease(event)
+	if err != nil {
+		// lets allow the webhook to be redelivered
+		o.processed.Remove(key)
+	}
+	return err
+}
+
+func (o *Options) processRelease(event *ReleaseEvent) error {
+	version := event.Version()
+	log.Logger().Infof("processing release %s of %s", info(version), info(event.GitURL))
+
+	matched := false
+	_, loader := pr.NewCmdPullRequest()
+	loader.CommandRunner = o.CommandRunner
+	loader.Gitter = o.GitClient
+	loader.UpdatebotClient = o.UpdatebotClient
+	for _, path := range o.ConfigFiles {
+		config, err := loader.LoadUpdateConfig(path)
+		if err != nil {
+			return fmt.Errorf("failed to load config file %s: %w", path, err)
+		}
+		if !pr.MatchesSourceURL(config, event.GitURL) {
+			continue
+		}
+		matched = true
+		err = o.createPullRequests(event, "", func(po *pr.Options) {
+			po.ConfigFile = path
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create Pull Requests for config file %s: %w", path, err)
+		}
+	}
+
+	if o.ConfigResources {
+		list, err := o.UpdatebotClient.UpdatebotV1alpha1().UpdateConfigs(o.ConfigNamespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to list UpdateConfig resources in namespace %s: %w", o.ConfigNamespace, err)
+		}
+		for i := range list.Items {
+			config := &list.Items[i]
+			if len(config.Spec.Includes) > 0 {
+				config, err = loader.LoadUpdateConfigResource(config.Name, config.Namespace)
+				if err != nil {
+					return fmt.Errorf("failed to load UpdateConfig %s: %w", list.Items[i].Name, err)
+				}
+			}
+			if !pr.MatchesSourceURL(config, event.GitURL) {
+				continue
+			}
+			matched = true
+			err = o.createPullRequests(event, "", func(po *pr.Options) {
+				po.ConfigResource = config.Name
+				po.ConfigNamespace = config.Namespace
+				po.UpdatebotClient = o.UpdatebotClient
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create Pull Requests for UpdateConfig %s: %w", config.Name, err)
+			}
+		}
+	}
+
+	if matched || !o.RepoConfig {
+		return nil
+	}
+	if !o.IsTrustedRepository(event.GitURL) || !o.IsTrustedRepository(event.CloneURL) {
+		log.Logger().Warnf("ignoring release %s of %s as it is not owned by a --repo-config-owner on %s", version, event.GitURL, o.ScmClientFactory.GitServerURL)
+		return nil
+	}
+
+	// lets use the configuration in the released repository
+	cloneDir, err := gitclient.CloneToDir(o.GitClient, event.CloneURL, "")
+	if err != nil {
+		return fmt.Errorf("failed to clone %s: %w", event.CloneURL, err)
+	}
+	defer os.RemoveAll(cloneDir)
+
+	err = gitclient.Checkout(o.GitClient, cloneDir, event.Tag)
+	if err != nil {
+		return fmt.Errorf("failed to checkout %s of %s: %w", event.Tag, event.CloneURL, err)
+	}
+	return o.createPullRequests(event, cloneDir, nil)
+}
+
+// createPullRequests runs the pr command for the release in the given dir or a temporary dir if blank
+func (o *Options) createPullRequests(event *ReleaseEvent, dir string, configure func(po *pr.Options)) error {
+	if dir == "" {
+		tmpDir, err := os.MkdirTemp("", "jx-updatebot-")
+		if err != nil {
+			return fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		defer os.RemoveAll(tmpDir)
+		dir = tmpDir
+	}
+
+	_, po := pr.NewCmdPullRequest()
+	po.Dir = dir
+	po.Version = event.Version()
+	po.AutoMerge = o.AutoMerge
+	po.Application = event.Application()
+	po.CommitMessage = fmt.Sprintf("from: %s\n", event.GitURL)
+	po.PipelineRepoURL = event.GitURL
+	po.PipelineCommitSha = event.SHA
+	po.CommandRunner = o.CommandRunner
+	po.ScmClientFactory = o.ScmClientFactory
+	if configure != nil {
+		configure(po)
+	}
+	return o.RunPullRequest(po)
+}
+
+// IsTrustedRepository returns true if the git URL is a repository on the git server owned by one of the repo config owners
+func (o *Options) IsTrustedRepository(gitURL string) bool {
+	server := strings.TrimSuffix(o.ScmClientFactory.GitServerURL, "/")
+	if server == "" || strings.Contains(gitURL, "..") {
+		return false
+	}
+	gitURL = strings.ToLower(gitops.TrimGitURLSuffix(gitURL))
+	for _, owner := range o.RepoConfigOwners {
+		prefix := strings.ToLower(server + "/" + strings.Trim(owner, "/") + "/")
+		if strings.HasPrefix(gitURL, prefix) && len(gitURL) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GitKindFromHeaders returns the kind of git server which sent the webhook
+func GitKindFromHeaders(header http.Header) string {
+	// Gitea and Gogs also send the GitHub headers so lets check them first
+	switch {
+	case header.Get("X-Gitea-Event") != "":
+		return "gitea"
+	case header.Get("X-Gogs-Event") != "":
+		return "gogs"
+	case header.Get("X-GitHub-Event") != "":
+		return "github"
+	case header.Get("X-Gitlab-Event") != "":
+		return "gitlab"
+	case header.Get("X-Event-Key") != "" && header.Get("X-Hook-UUID") != "":
+		return "bitbucketcloud"
+	case header.Get("X-Event-Key") != "":
+		return "bitbucketserver"
+	default:
+		return strings.ToLower(os.Getenv("GIT_KIND"))
+	}
+}
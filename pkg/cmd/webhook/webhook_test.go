@@ -0,0 +1,184 @@
+package webhook_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/pr"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/cmd/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hmacToken = "mysecret"
+
+func TestWebhook(t *testing.T) {
+	var lock sync.Mutex
+	var triggered []string
+
+	_, o := webhook.NewCmdWebhook()
+	o.HMACToken = hmacToken
+	o.ConfigFiles = []string{
+		filepath.Join("test_data", "hello-world.yaml"),
+		filepath.Join("test_data", "drone-test-go.yaml"),
+	}
+	o.RunPullRequest = func(po *pr.Options) error {
+		lock.Lock()
+		defer lock.Unlock()
+		triggered = append(triggered, filepath.Base(po.ConfigFile)+":"+po.Application+":"+po.Version)
+		return nil
+	}
+	err := o.Validate()
+	require.NoError(t, err, "failed to validate")
+
+	testCases := []struct {
+		name       string
+		event      string
+		file       string
+		secret     string
+		method     string
+		statusCode int
+	}{
+		{
+			name:       "release",
+			event:      "release",
+			file:       "release.json",
+			secret:     hmacToken,
+			statusCode: http.StatusAccepted,
+		},
+		{
+			name:       "duplicate-release",
+			event:      "release",
+			file:       "release.json",
+			secret:     hmacToken,
+			statusCode: http.StatusAccepted,
+		},
+		{
+			name:       "push-tag",
+			event:      "push",
+			file:       "push_tag.json",
+			secret:     hmacToken,
+			statusCode: http.StatusAccepted,
+		},
+		{
+			name:       "invalid-signature",
+			event:      "release",
+			file:       "release.json",
+			secret:     "wrongsecret",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "get",
+			method:     http.MethodGet,
+			statusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		var body []byte
+		if tc.file != "" {
+			body, err = os.ReadFile(filepath.Join("test_data", tc.file))
+			require.NoError(t, err, "failed to load %s", tc.file)
+		}
+		method := tc.method
+		if method == "" {
+			method = http.MethodPost
+		}
+		req := httptest.NewRequest(method, "/hook", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", tc.event)
+		req.Header.Set("X-GitHub-Delivery", "f2467dea-70d6-11e8-8955-3c83993e0aef")
+		req.Header.Set("X-Hub-Signature", sign(tc.secret, body))
+
+		w := httptest.NewRecorder()
+		o.ServeHTTP(w, req)
+		assert.Equal(t, tc.statusCode, w.Code, "status code for test %s", tc.name)
+		o.Wait()
+	}
+
+	assert.Equal(t, []string{
+		"hello-world.yaml:Codertocat/Hello-World:0.0.1",
+		"drone-test-go.yaml:bradrydzewski/drone-test-go:0.0.1",
+	}, triggered, "should create Pull Requests once for each release")
+}
+
+func TestWebhookValidate(t *testing.T) {
+	_, o := webhook.NewCmdWebhook()
+	o.HMACToken = ""
+	err := o.Validate()
+	require.Error(t, err, "should require a --hmac-token")
+
+	o.InsecureSkipHMAC = true
+	err = o.Validate()
+	require.NoError(t, err, "should allow no --hmac-token with --insecure-skip-hmac")
+
+	o.RepoConfig = true
+	err = o.Validate()
+	require.Error(t, err, "should require a --git-server with --repo-config")
+
+	o.ScmClientFactory.GitServerURL = "https://github.com"
+	err = o.Validate()
+	require.Error(t, err, "should require a --repo-config-owner with --repo-config")
+
+	o.RepoConfigOwners = []string{"myorg"}
+	err = o.Validate()
+	require.NoError(t, err, "should validate --repo-config")
+}
+
+func TestIsTrustedRepository(t *testing.T) {
+	_, o := webhook.NewCmdWebhook()
+	o.ScmClientFactory.GitServerURL = "https://github.com/"
+	o.RepoConfigOwners = []string{"myorg"}
+
+	testCases := []struct {
+		gitURL   string
+		expected bool
+	}{
+		{gitURL: "https://github.com/myorg/myapp", expected: true},
+		{gitURL: "https://github.com/MyOrg/myapp.git", expected: true},
+		{gitURL: "https://github.com/myorg/", expected: false},
+		{gitURL: "https://github.com/myorg-evil/myapp", expected: false},
+		{gitURL: "https://github.com/other/myapp", expected: false},
+		{gitURL: "https://github.com.evil.com/myorg/myapp", expected: false},
+		{gitURL: "https://github.com/myorg/../other/myapp", expected: false},
+		{gitURL: "git@github.com:myorg/myapp.git", expected: false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, o.IsTrustedRepository(tc.gitURL), "trusted repository %s", tc.gitURL)
+	}
+}
+
+func TestGitKindFromHeaders(t *testing.T) {
+	testCases := []struct {
+		headers  map[string]string
+		expected string
+	}{
+		{headers: map[string]string{"X-GitHub-Event": "release"}, expected: "github"},
+		{headers: map[string]string{"X-GitHub-Event": "release", "X-Gitea-Event": "release", "X-Gogs-Event": "release"}, expected: "gitea"},
+		{headers: map[string]string{"X-GitHub-Event": "release", "X-Gogs-Event": "release"}, expected: "gogs"},
+		{headers: map[string]string{"X-Gitlab-Event": "Tag Push Hook"}, expected: "gitlab"},
+		{headers: map[string]string{"X-Event-Key": "repo:push", "X-Hook-UUID": "abc"}, expected: "bitbucketcloud"},
+		{headers: map[string]string{"X-Event-Key": "repo:refs_changed"}, expected: "bitbucketserver"},
+	}
+	for _, tc := range testCases {
+		header := http.Header{}
+		for k, v := range tc.headers {
+			header.Set(k, v)
+		}
+		assert.Equal(t, tc.expected, webhook.GitKindFromHeaders(header), "git kind for headers %v", tc.headers)
+	}
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
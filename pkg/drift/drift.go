@@ -0,0 +1,165 @@
+package drift
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+)
+
+// Distance how far the target version is from the source version
+type Distance string
+
+const (
+	// DistanceNone the versions are the same
+	DistanceNone Distance = "none"
+
+	// DistancePatch the target is behind by a patch version
+	DistancePatch Distance = "patch"
+
+	// DistanceMinor the target is behind by a minor version
+	DistanceMinor Distance = "minor"
+
+	// DistanceMajor the target is behind by a major version
+	DistanceMajor Distance = "major"
+
+	// DistanceAhead the target is newer than the source
+	DistanceAhead Distance = "ahead"
+
+	// DistanceUnknown the versions are different but are not semantic versions
+	DistanceUnknown Distance = "unknown"
+
+	// DistanceMissing the target does not contain the chart or application
+	DistanceMissing Distance = "missing"
+)
+
+// Distances the distances which can be used to fail a drift report
+var Distances = []string{string(DistancePatch), string(DistanceMinor), string(DistanceMajor)}
+
+// Version the version of a chart or application found in a git repository
+type Version struct {
+	// Key the key used to match the source and target versions
+	Key string
+
+	// Name the name displayed in the report
+	Name string
+
+	// Version the version
+	Version string
+
+	// FallbackKey the key used to match the target versions if there is no target version with the same key
+	FallbackKey string
+}
+
+// Drift the difference between the source and target version of a chart or application
+type Drift struct {
+	Name     string   `json:"name"`
+	Source   string   `json:"sourceVersion"`
+	Target   string   `json:"targetVersion,omitempty"`
+	Distance Distance `json:"distance"`
+}
+
+// InSync returns true if the target has the same version as the source
+func (d *Drift) InSync() bool {
+	return d.Distance == DistanceNone
+}
+
+// Severity returns the severity of the drift where 0 is in sync and 3 is a major version behind. Ahead versions have
+// no severity whereas missing and non semantic versions are treated like a major version
+func (d *Drift) Severity() int {
+	switch d.Distance {
+	case DistancePatch:
+		return 1
+	case DistanceMinor:
+		return 2
+	case DistanceMajor, DistanceMissing, DistanceUnknown:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Compare compares the source versions to the target versions returning the drifts sorted by name. If there is no
+// target version with the same key then the target versions with the same fallback key are used if it is specified
+func Compare(sources, targets []*Version) []*Drift {
+	targetVersions := map[string]*Version{}
+	fallbackVersions := map[string][]string{}
+	for _, v := range targets {
+		targetVersions[v.Key] = v
+		if v.FallbackKey != "" && stringhelpers.StringArrayIndex(fallbackVersions[v.FallbackKey], v.Version) < 0 {
+			fallbackVersions[v.FallbackKey] = append(fallbackVersions[v.FallbackKey], v.Version)
+		}
+	}
+
+	var answer []*Drift
+	found := map[string]bool{}
+	for _, s := range sources {
+		if found[s.Key] {
+			continue
+		}
+		found[s.Key] = true
+		d := &Drift{
+			Name:   s.Name,
+			Source: s.Version,
+		}
+		t := targetVersions[s.Key]
+		var versions []string
+		if t != nil {
+			versions = []string{t.Version}
+		} else if s.FallbackKey != "" {
+			versions = fallbackVersions[s.FallbackKey]
+		}
+		switch len(versions) {
+		case 0:
+			d.Distance = DistanceMissing
+		case 1:
+			d.Target = versions[0]
+			d.Distance = GetDistance(s.Version, d.Target)
+		default:
+			// several target versions match so we can't tell how far behind the target is
+			sorted := append([]string{}, versions...)
+			sort.Strings(sorted)
+			d.Target = strings.Join(sorted, ", ")
+			d.Distance = DistanceUnknown
+		}
+		answer = append(answer, d)
+	}
+	sort.SliceStable(answer, func(i, j int) bool {
+		return answer[i].Name < answer[j].Name
+	})
+	return answer
+}
+
+// GetDistance returns how far the target version is behind the source version
+func GetDistance(source, target string) Distance {
+	if source == target {
+		return DistanceNone
+	}
+	sv, err := semver.ParseTolerant(source)
+	if err != nil {
+		return DistanceUnknown
+	}
+	tv, err := semver.ParseTolerant(target)
+	if err != nil {
+		return DistanceUnknown
+	}
+	switch {
+	case sv.EQ(tv):
+		return DistanceNone
+	case tv.GT(sv):
+		return DistanceAhead
+	case sv.Major != tv.Major:
+		return DistanceMajor
+	case sv.Minor != tv.Minor:
+		return DistanceMinor
+	default:
+		return DistancePatch
+	}
+}
+
+// SeverityOf returns the severity of the given distance name
+func SeverityOf(distance string) int {
+	d := &Drift{Distance: Distance(distance)}
+	return d.Severity()
+}
@@ -0,0 +1,91 @@
+package drift_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/drift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDistance(t *testing.T) {
+	testCases := []struct {
+		source   string
+		target   string
+		expected drift.Distance
+	}{
+		{source: "1.2.3", target: "1.2.3", expected: drift.DistanceNone},
+		{source: "v1.2.3", target: "1.2.3", expected: drift.DistanceNone},
+		{source: "1.2.3", target: "1.2.1", expected: drift.DistancePatch},
+		{source: "1.3.0", target: "1.2.9", expected: drift.DistanceMinor},
+		{source: "v2.0.0", target: "v1.9.9", expected: drift.DistanceMajor},
+		{source: "1.2.3", target: "1.3.0", expected: drift.DistanceAhead},
+		{source: "main", target: "1.2.3", expected: drift.DistanceUnknown},
+		{source: "sha256:abc", target: "sha256:def", expected: drift.DistanceUnknown},
+	}
+	for _, tc := range testCases {
+		got := drift.GetDistance(tc.source, tc.target)
+		assert.Equal(t, tc.expected, got, "distance from source %s to target %s", tc.source, tc.target)
+	}
+}
+
+func TestCompareFallbackKey(t *testing.T) {
+	drifts := drift.Compare(
+		[]*drift.Version{
+			{Key: "staging/ale", Name: "ale", Version: "2.0.0", FallbackKey: "ale"},
+			{Key: "staging/beer", Name: "beer", Version: "1.1.0", FallbackKey: "beer"},
+			{Key: "staging/wine", Name: "wine", Version: "1.0.0"},
+		},
+		[]*drift.Version{
+			{Key: "production/ale", Name: "ale", Version: "1.0.0", FallbackKey: "ale"},
+			{Key: "eu/beer", Name: "beer", Version: "1.1.0", FallbackKey: "beer"},
+			{Key: "us/beer", Name: "beer", Version: "1.0.0", FallbackKey: "beer"},
+			{Key: "production/wine", Name: "wine", Version: "1.0.0", FallbackKey: "wine"},
+		},
+	)
+	require.Len(t, drifts, 3)
+	assert.Equal(t, &drift.Drift{Name: "ale", Source: "2.0.0", Target: "1.0.0", Distance: drift.DistanceMajor}, drifts[0])
+	assert.Equal(t, &drift.Drift{Name: "beer", Source: "1.1.0", Target: "1.0.0, 1.1.0", Distance: drift.DistanceUnknown}, drifts[1])
+	assert.Equal(t, &drift.Drift{Name: "wine", Source: "1.0.0", Distance: drift.DistanceMissing}, drifts[2], "no fallback key on the source")
+}
+
+func TestWriteReport(t *testing.T) {
+	drifts := drift.Compare(
+		[]*drift.Version{
+			{Key: "b", Name: "beer", Version: "1.1.0"},
+			{Key: "a", Name: "ale", Version: "2.0.0"},
+			{Key: "w", Name: "wine", Version: "1.0.0"},
+		},
+		[]*drift.Version{
+			{Key: "a", Name: "ale", Version: "1.0.0"},
+			{Key: "b", Name: "beer", Version: "1.1.0"},
+		},
+	)
+	require.Len(t, drifts, 3)
+	assert.Equal(t, "ale", drifts[0].Name)
+	assert.Equal(t, drift.DistanceMajor, drifts[0].Distance)
+	assert.True(t, drifts[1].InSync(), "beer should be in sync")
+	assert.Equal(t, drift.DistanceMissing, drifts[2].Distance)
+
+	buf := &bytes.Buffer{}
+	err := drift.WriteReport(buf, drift.FormatJSON, drifts)
+	require.NoError(t, err, "failed to write JSON")
+	var results []*drift.Drift
+	err = json.Unmarshal(buf.Bytes(), &results)
+	require.NoError(t, err, "failed to parse JSON %s", buf.String())
+	assert.Equal(t, drifts, results)
+
+	buf.Reset()
+	err = drift.WriteReport(buf, drift.FormatTable, drifts)
+	require.NoError(t, err, "failed to write table")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, []string{"NAME", "SOURCE", "TARGET", "DISTANCE"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"ale", "2.0.0", "1.0.0", "major"}, strings.Fields(lines[1]))
+
+	err = drift.WriteReport(buf, "yaml", drifts)
+	require.Error(t, err, "should not support yaml")
+}
@@ -0,0 +1,57 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// FormatTable a plain text table
+	FormatTable = "table"
+
+	// FormatJSON a JSON array
+	FormatJSON = "json"
+
+	// FormatMarkdown a markdown table
+	FormatMarkdown = "markdown"
+)
+
+// Formats the supported report formats
+var Formats = []string{FormatTable, FormatJSON, FormatMarkdown}
+
+// WriteReport writes the drifts to the writer in the given format
+func WriteReport(w io.Writer, format string, drifts []*Drift) error {
+	switch format {
+	case FormatJSON:
+		if drifts == nil {
+			drifts = []*Drift{}
+		}
+		data, err := json.MarshalIndent(drifts, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal drifts to JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case FormatMarkdown:
+		buf := strings.Builder{}
+		buf.WriteString("| Name | Source | Target | Distance |\n")
+		buf.WriteString("| --- | --- | --- | --- |\n")
+		for _, d := range drifts {
+			buf.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", d.Name, d.Source, d.Target, d.Distance))
+		}
+		_, err := io.WriteString(w, buf.String())
+		return err
+	case FormatTable, "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSOURCE\tTARGET\tDISTANCE")
+		for _, d := range drifts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", d.Name, d.Source, d.Target, d.Distance)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported format %s. Supported formats: %s", format, strings.Join(Formats, ", "))
+	}
+}
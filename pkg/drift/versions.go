@@ -0,0 +1,129 @@
+package drift
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-gitops/pkg/helmfiles"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/argocd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/fluxcd"
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// HelmfileVersions returns the versions of the helmfile releases in the dir. If a namespace is specified only its
+// releases are returned. If byName is true releases are matched by release name alone so that different namespaces
+// can be compared
+func HelmfileVersions(dir, helmfile, namespace string, byName bool) ([]*Version, error) {
+	hfs, err := helmfiles.GatherHelmfiles(helmfile, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather helmfiles from %s: %w", dir, err)
+	}
+	var answer []*Version
+	for i := range hfs {
+		path := hfs[i].Filepath
+		helmStates, err := helmfiles.LoadHelmfile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load helmfile %s: %w", path, err)
+		}
+		for _, helmState := range helmStates {
+			for j := range helmState.Releases {
+				rel := &helmState.Releases[j]
+				if rel.Version == "" {
+					continue
+				}
+				ns := rel.Namespace
+				if ns == "" {
+					ns = helmState.OverrideNamespace
+				}
+				name := rel.Name
+				if name == "" {
+					_, name = helmfiles.SpitChartName(rel.Chart)
+				}
+				if namespace != "" && ns != namespace {
+					continue
+				}
+				if !byName && ns != "" {
+					name = ns + "/" + name
+				}
+				answer = append(answer, &Version{Key: name, Name: name, Version: rel.Version})
+			}
+		}
+	}
+	return answer, nil
+}
+
+// ArgoVersions returns the versions of the sources of the ArgoCD Applications and ApplicationSets in the dir
+func ArgoVersions(dir string) ([]*Version, error) {
+	var answer []*Version
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		for _, v := range argocd.GetAppVersions(node, path) {
+			if v.RepoURL == "" || v.Version == "" {
+				continue
+			}
+			name := v.Chart
+			if name == "" {
+				name = gitops.TrimGitURLSuffix(v.RepoURL)
+				if v.Path != "" {
+					name += "//" + v.Path
+				}
+			}
+			answer = append(answer, &Version{Key: v.Key(), Name: name, Version: v.Version})
+		}
+		return false, nil
+	}
+	err := kyamls.ModifyFiles(dir, modifyFn, argocd.ApplicationFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Applications in %s: %w", dir, err)
+	}
+	return answer, nil
+}
+
+// FluxVersions returns the versions of the Flux HelmReleases and the GitRepository, OCIRepository and Bucket sources in the dir.
+// If chartFallback is true HelmReleases which are not matched by name, namespace and target namespace are matched by their
+// chart and sourceRef name
+func FluxVersions(dir string, chartFallback bool) ([]*Version, error) {
+	sources, err := fluxcd.LoadChartRefSources(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart ref sources from %s: %w", dir, err)
+	}
+	var answer []*Version
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		v, _ := fluxcd.GetHelmReleaseChartVersion(sources, node, path)
+		if v.Chart == "" || v.Version == "" {
+			return false, nil
+		}
+		name := v.Name
+		if v.ReleaseNamespace != "" {
+			name = v.ReleaseNamespace + "/" + name
+		}
+		version := &Version{Key: v.Key(), Name: name, Version: v.Version}
+		if chartFallback {
+			version.FallbackKey = v.ChartKey()
+		}
+		answer = append(answer, version)
+		return false, nil
+	}
+	err = kyamls.ModifyFiles(dir, modifyFn, fluxcd.HelmReleaseKindFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find HelmReleases in %s: %w", dir, err)
+	}
+
+	modifyFn = func(node *yaml.RNode, path string) (bool, error) {
+		v := fluxcd.GetSourceVersion(node, path)
+		if v.Version == "" {
+			return false, nil
+		}
+		name := v.Kind + "/" + v.Name
+		if v.Namespace != "" {
+			name = v.Kind + "/" + v.Namespace + "/" + v.Name
+		}
+		answer = append(answer, &Version{Key: v.Key(), Name: name, Version: v.Version})
+		return false, nil
+	}
+	err = kyamls.ModifyFiles(dir, modifyFn, fluxcd.SourceKindFilter(fluxcd.SourceKinds...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find sources in %s: %w", dir, err)
+	}
+	return answer, nil
+}
@@ -0,0 +1,148 @@
+package fluxcd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ChartRef a reference from a HelmRelease spec.chartRef to an OCIRepository or HelmChart
+type ChartRef struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// Key returns a unique key for the referenced resource
+func (r *ChartRef) Key() string {
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// GetChartRef returns the spec.chartRef of the HelmRelease or nil if it has none.
+// The namespace defaults to the namespace of the HelmRelease
+func GetChartRef(node *yaml.RNode, path string) *ChartRef {
+	r := &ChartRef{
+		Kind:      kyamls.GetStringField(node, path, "spec", "chartRef", "kind"),
+		Name:      kyamls.GetStringField(node, path, "spec", "chartRef", "name"),
+		Namespace: kyamls.GetStringField(node, path, "spec", "chartRef", "namespace"),
+	}
+	if r.Kind == "" || r.Name == "" {
+		return nil
+	}
+	if r.Namespace == "" {
+		r.Namespace = kyamls.GetNamespace(node, path)
+	}
+	return r
+}
+
+// ChartRefSource an OCIRepository or HelmChart which can be referenced by a HelmRelease
+type ChartRefSource struct {
+	ChartRef
+	Path    string
+	Version *ChartVersion
+}
+
+// LoadChartRefSources loads the OCIRepository and HelmChart resources in the given dir indexed by their ChartRef key
+func LoadChartRefSources(dir string) (map[string]*ChartRefSource, error) {
+	answer := map[string]*ChartRefSource{}
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		s := &ChartRefSource{
+			ChartRef: ChartRef{
+				Kind:      kyamls.GetKind(node, path),
+				Name:      kyamls.GetName(node, path),
+				Namespace: kyamls.GetNamespace(node, path),
+			},
+			Path:    path,
+			Version: GetChartRefSourceVersion(node, path),
+		}
+		answer[s.Key()] = s
+		return false, nil
+	}
+	err := kyamls.ModifyFiles(dir, modifyFn, ChartRefKindFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart references in dir %s: %w", dir, err)
+	}
+	return answer, nil
+}
+
+// ResolveChartRef returns the source referenced by the HelmRelease spec.chartRef or nil if it has none or it cannot be found
+func ResolveChartRef(sources map[string]*ChartRefSource, node *yaml.RNode, path string) *ChartRefSource {
+	ref := GetChartRef(node, path)
+	if ref == nil {
+		return nil
+	}
+	s := sources[ref.Key()]
+	if s == nil && ref.Namespace != "" {
+		// lets allow the source to be defined without a namespace
+		s = sources[ref.Kind+"//"+ref.Name]
+	}
+	if s == nil {
+		log.Logger().Warnf("could not find the %s %s referenced by the HelmRelease in file %s", ref.Kind, ref.Name, path)
+	}
+	return s
+}
+
+// GetChartRefSourceVersion gets the ChartVersion of an OCIRepository or HelmChart. The chart of an OCIRepository
+// is the last path of its URL
+func GetChartRefSourceVersion(node *yaml.RNode, path string) *ChartVersion {
+	v := &ChartVersion{
+		Namespace: kyamls.GetNamespace(node, path),
+		Labels:    node.GetLabels(),
+	}
+	switch kyamls.GetKind(node, path) {
+	case "OCIRepository":
+		url := strings.TrimSuffix(kyamls.GetStringField(node, path, "spec", "url"), "/")
+		v.Chart = url[strings.LastIndex(url, "/")+1:]
+		v.SourceRefName = kyamls.GetName(node, path)
+	case "HelmChart":
+		v.Chart = kyamls.GetStringField(node, path, "spec", "chart")
+		v.SourceRefName = kyamls.GetStringField(node, path, "spec", "sourceRef", "name")
+	}
+	v.Version = kyamls.GetStringField(node, path, sourceVersionFields(node, path)...)
+	return v
+}
+
+// SetChartRefSourceVersions sets the versions of the OCIRepository and HelmChart resources in the dir with the given keys
+func SetChartRefSourceVersions(dir string, versions map[string]string) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		ref := ChartRef{
+			Kind:      kyamls.GetKind(node, path),
+			Name:      kyamls.GetName(node, path),
+			Namespace: kyamls.GetNamespace(node, path),
+		}
+		version, ok := versions[ref.Key()]
+		if !ok {
+			return false, nil
+		}
+		err := SetSourceVersion(node, path, version)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return kyamls.ModifyFiles(dir, modifyFn, ChartRefKindFilter)
+}
+
+// GetHelmReleaseChartVersion gets the ChartVersion of the HelmRelease following any spec.chartRef to the referenced
+// source which is also returned
+func GetHelmReleaseChartVersion(sources map[string]*ChartRefSource, node *yaml.RNode, path string) (*ChartVersion, *ChartRefSource) {
+	s := ResolveChartRef(sources, node, path)
+	if s == nil {
+		return GetChartVersion(node, path), nil
+	}
+	v := *s.Version
+	release := GetChartVersion(node, path)
+	v.Name = release.Name
+	v.ReleaseNamespace = release.ReleaseNamespace
+	v.TargetNamespace = release.TargetNamespace
+	v.Namespace = release.Namespace
+	v.Labels = release.Labels
+	return &v, s
+}
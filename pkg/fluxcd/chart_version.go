@@ -11,13 +11,23 @@ import (
 
 // ChartVersion represents a helm chart release version metadata from an Fluxcd HelmRelease resource
 type ChartVersion struct {
-	Chart         string
-	Version       string
-	SourceRefName string
+	Chart            string
+	Version          string
+	SourceRefName    string
+	Name             string
+	ReleaseNamespace string
+	TargetNamespace  string
+	Namespace        string
+	Labels           map[string]string
 }
 
-// Key returns a unique key for the helm chart version
+// Key returns a unique key for the helm release using its name, namespace and target namespace along with the chart
 func (v *ChartVersion) Key() string {
+	return v.ReleaseNamespace + "/" + v.Name + "\n" + v.TargetNamespace + "\n" + v.ChartKey()
+}
+
+// ChartKey returns a key for the helm chart which is shared by all releases of the same chart
+func (v *ChartVersion) ChartKey() string {
 	return v.Chart + "\n" + v.SourceRefName
 }
 
@@ -27,7 +37,11 @@ func (v *ChartVersion) String() string {
 	if v.SourceRefName != "" {
 		sep = " sourceRefName: " + v.SourceRefName
 	}
-	return "repo: " + v.Chart + sep + " version: " + v.Version
+	name := ""
+	if v.Name != "" {
+		name = "release: " + v.ReleaseNamespace + "/" + v.Name + " "
+	}
+	return name + "repo: " + v.Chart + sep + " version: " + v.Version
 }
 
 // GetChartVersion gets the ChartVersion from the given YAML file
@@ -36,6 +50,14 @@ func GetChartVersion(node *yaml.RNode, path string) *ChartVersion {
 	v.Chart = kyamls.GetStringField(node, path, "spec", "chart", "spec", "chart")
 	v.Version = kyamls.GetStringField(node, path, "spec", "chart", "spec", "version")
 	v.SourceRefName = kyamls.GetStringField(node, path, "spec", "chart", "spec", "sourceRef", "name")
+	v.Name = kyamls.GetName(node, path)
+	v.ReleaseNamespace = kyamls.GetNamespace(node, path)
+	v.TargetNamespace = kyamls.GetStringField(node, path, "spec", "targetNamespace")
+	v.Namespace = v.TargetNamespace
+	if v.Namespace == "" {
+		v.Namespace = v.ReleaseNamespace
+	}
+	v.Labels = node.GetLabels()
 	return v
 }
 
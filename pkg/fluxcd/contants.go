@@ -6,6 +6,15 @@ import (
 
 var (
 	HelmReleaseKindFilter = kyamls.Filter{
-		Kinds: []string{"helm.toolkit.fluxcd.io/v2beta1/HelmRelease"},
+		Kinds: []string{
+			"helm.toolkit.fluxcd.io/v2beta1/HelmRelease",
+			"helm.toolkit.fluxcd.io/v2beta2/HelmRelease",
+			"helm.toolkit.fluxcd.io/v2/HelmRelease",
+		},
+	}
+
+	// ChartRefKindFilter the kinds of source which can be referenced by a HelmRelease spec.chartRef
+	ChartRefKindFilter = kyamls.Filter{
+		Kinds: []string{"source.toolkit.fluxcd.io/v1/OCIRepository", "source.toolkit.fluxcd.io/v1/HelmChart"},
 	}
 )
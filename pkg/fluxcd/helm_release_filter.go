@@ -1,8 +1,9 @@
 package fluxcd
 
 import (
+	"fmt"
+
 	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
 	"github.com/spf13/cobra"
 )
 
@@ -10,20 +11,42 @@ import (
 type HelmReleaseFilter struct {
 	Chart         gitops.TextFilter
 	SourceRefName gitops.TextFilter
+	Namespace     gitops.TextFilter
+	Labels        gitops.LabelFilter
 }
 
 // Matches return true if the app version matches the filter
 func (o *HelmReleaseFilter) Matches(v *ChartVersion) bool {
-	if !stringhelpers.StringContainsAny(v.Chart, o.Chart.Includes, o.Chart.Excludes) {
-		return false
-	}
-	if !stringhelpers.StringContainsAny(v.SourceRefName, o.SourceRefName.Includes, o.SourceRefName.Excludes) {
-		return false
+	return o.Chart.Matches(v.Chart) &&
+		o.SourceRefName.Matches(v.SourceRefName) &&
+		o.Namespace.Matches(v.Namespace) &&
+		o.Labels.Matches(v.Labels)
+}
+
+// MatchesSource return true if the namespace and labels of the source version match the filter
+func (o *HelmReleaseFilter) MatchesSource(v *SourceVersion) bool {
+	return o.Namespace.Matches(v.Namespace) &&
+		o.Labels.Matches(v.Labels)
+}
+
+// Validate validates the filter patterns
+func (o *HelmReleaseFilter) Validate() error {
+	for name, f := range map[string]*gitops.TextFilter{
+		"chart":           &o.Chart,
+		"source-ref-name": &o.SourceRefName,
+		"namespace":       &o.Namespace,
+	} {
+		err := f.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid %s filter: %w", name, err)
+		}
 	}
-	return true
+	return o.Labels.Validate()
 }
 
 func (o *HelmReleaseFilter) AddFlags(cmd *cobra.Command) {
 	o.Chart.AddFlags(cmd, "chart", "chart name")
-	o.Chart.AddFlags(cmd, "source-ref-name", "the sourceRef name of the chart repository or bucket")
+	o.SourceRefName.AddFlags(cmd, "source-ref-name", "the sourceRef name of the chart repository or bucket")
+	o.Namespace.AddFlags(cmd, "namespace", "target namespace of the helm release")
+	o.Labels.AddFlags(cmd, "helm releases")
 }
@@ -46,6 +46,23 @@ func TestAppFilter(t *testing.T) {
 				{Chart: "https://github.com/myorg/app1", SourceRefName: "cheese"},
 			},
 		},
+		{
+			filter: fluxcd.HelmReleaseFilter{
+				SourceRefName: gitops.TextFilter{
+					Excludes: []string{"cheese"},
+				},
+				Namespace: gitops.TextFilter{
+					Includes: []string{"glob:team-*"},
+				},
+			},
+			matches: []fluxcd.ChartVersion{
+				{Chart: "app1", SourceRefName: "wine", Namespace: "team-a"},
+			},
+			notMatches: []fluxcd.ChartVersion{
+				{Chart: "app1", SourceRefName: "cheese", Namespace: "team-a"},
+				{Chart: "app1", SourceRefName: "wine", Namespace: "default"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
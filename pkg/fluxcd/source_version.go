@@ -0,0 +1,105 @@
+package fluxcd
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SourceKinds the kinds of Flux source whose version can be promoted and synchronised
+var SourceKinds = []string{"GitRepository", "OCIRepository", "Bucket"}
+
+// SourceVersion represents the version of a Flux GitRepository, OCIRepository or Bucket
+type SourceVersion struct {
+	Kind      string
+	Name      string
+	Namespace string
+	URL       string
+	Version   string
+	Labels    map[string]string
+
+	// Semver is true if the version is a spec.ref.semver range rather than a spec.ref.tag
+	Semver bool
+}
+
+// Key returns a unique key for the source
+func (v *SourceVersion) Key() string {
+	return v.Kind + "/" + v.Namespace + "/" + v.Name
+}
+
+// String returns the string summary of the source version
+func (v *SourceVersion) String() string {
+	return v.Kind + ": " + v.Name + " url: " + v.URL + " version: " + v.Version
+}
+
+// SourceKindFilter returns the filter for the given kinds of Flux source
+func SourceKindFilter(kinds ...string) kyamls.Filter {
+	f := kyamls.Filter{}
+	for _, k := range kinds {
+		f.Kinds = append(f.Kinds, "source.toolkit.fluxcd.io/v1/"+k)
+	}
+	return f
+}
+
+// GetSourceVersion gets the SourceVersion of the GitRepository, OCIRepository or Bucket
+func GetSourceVersion(node *yaml.RNode, path string) *SourceVersion {
+	v := &SourceVersion{
+		Kind:      kyamls.GetKind(node, path),
+		Name:      kyamls.GetName(node, path),
+		Namespace: kyamls.GetNamespace(node, path),
+		Labels:    node.GetLabels(),
+	}
+	if v.Kind == "Bucket" {
+		v.URL = kyamls.GetStringField(node, path, "spec", "bucketName")
+	} else {
+		v.URL = kyamls.GetStringField(node, path, "spec", "url")
+	}
+	fields := sourceVersionFields(node, path)
+	v.Version = kyamls.GetStringField(node, path, fields...)
+	v.Semver = fields[len(fields)-1] == "semver"
+	return v
+}
+
+// LoadRepositoryURLs loads the URLs of the HelmRepository and OCIRepository resources in the given dir indexed by name
+func LoadRepositoryURLs(dir string) (map[string]string, error) {
+	answer := map[string]string{}
+	modifyFn := func(node *yaml.RNode, path string) (bool, error) {
+		answer[kyamls.GetName(node, path)] = kyamls.GetStringField(node, path, "spec", "url")
+		return false, nil
+	}
+	err := kyamls.ModifyFiles(dir, modifyFn, SourceKindFilter("HelmRepository", "OCIRepository"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load repositories in dir %s: %w", dir, err)
+	}
+	return answer, nil
+}
+
+// SetSourceVersion sets the version of the GitRepository, OCIRepository, Bucket or HelmChart
+func SetSourceVersion(node *yaml.RNode, path, version string) error {
+	fields := sourceVersionFields(node, path)
+	err := node.PipeE(yaml.LookupCreate(yaml.ScalarNode, fields...), yaml.FieldSetter{StringValue: version})
+	if err != nil {
+		return fmt.Errorf("failed to set %s to %s: %w", kyamls.JSONPath(fields...), version, err)
+	}
+	log.Logger().Debugf("modified the version in file %s to %s", path, version)
+	return nil
+}
+
+// sourceVersionFields returns the path to the version of the source. The spec.ref.tag of a GitRepository or
+// OCIRepository is used unless only the spec.ref.semver is specified. The version of a Bucket is its spec.prefix
+func sourceVersionFields(node *yaml.RNode, path string) []string {
+	switch kyamls.GetKind(node, path) {
+	case "GitRepository", "OCIRepository":
+		if kyamls.GetStringField(node, path, "spec", "ref", "tag") == "" && kyamls.GetStringField(node, path, "spec", "ref", "semver") != "" {
+			return []string{"spec", "ref", "semver"}
+		}
+		return []string{"spec", "ref", "tag"}
+	case "Bucket":
+		return []string{"spec", "prefix"}
+	default:
+		return []string{"spec", "version"}
+	}
+}
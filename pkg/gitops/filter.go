@@ -1,14 +1,121 @@
 package gitops
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
 
-// TextFilter filters text
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// GlobPrefix the prefix of a filter pattern which uses glob matching
+	GlobPrefix = "glob:"
+
+	// RegexPrefix the prefix of a filter pattern which uses regular expression matching
+	RegexPrefix = "regex:"
+)
+
+// TextFilter filters text. Patterns match if they are contained in the text unless
+// they are prefixed with glob: or regex:
 type TextFilter struct {
 	Includes []string
 	Excludes []string
 }
 
 func (o *TextFilter) AddFlags(cmd *cobra.Command, optionPrefix, name string) {
-	cmd.Flags().StringSliceVar(&o.Includes, optionPrefix+"-include", []string{}, "text strings in the "+name+" to be included when synchronising")
-	cmd.Flags().StringSliceVar(&o.Includes, optionPrefix+"-exclude", []string{}, "text strings in the "+name+" to be excluded when synchronising")
+	cmd.Flags().StringSliceVar(&o.Includes, optionPrefix+"-include", []string{}, "text strings in the "+name+" to be included when synchronising. Prefix with glob: or regex: to use glob or regular expression matching")
+	cmd.Flags().StringSliceVar(&o.Excludes, optionPrefix+"-exclude", []string{}, "text strings in the "+name+" to be excluded when synchronising. Prefix with glob: or regex: to use glob or regular expression matching")
+}
+
+// Validate validates the glob and regular expression patterns
+func (o *TextFilter) Validate() error {
+	for _, p := range append(append([]string{}, o.Includes...), o.Excludes...) {
+		switch {
+		case strings.HasPrefix(p, GlobPrefix):
+			_, err := path.Match(strings.TrimPrefix(p, GlobPrefix), "")
+			if err != nil {
+				return fmt.Errorf("invalid glob pattern %s: %w", p, err)
+			}
+		case strings.HasPrefix(p, RegexPrefix):
+			_, err := regexp.Compile(strings.TrimPrefix(p, RegexPrefix))
+			if err != nil {
+				return fmt.Errorf("invalid regular expression %s: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the text matches any include pattern and no exclude pattern
+func (o *TextFilter) Matches(text string) bool {
+	for _, p := range o.Excludes {
+		if PatternMatches(p, text) {
+			return false
+		}
+	}
+	if len(o.Includes) == 0 {
+		return true
+	}
+	for _, p := range o.Includes {
+		if PatternMatches(p, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// PatternMatches returns true if the text contains the pattern or matches the glob: or regex: pattern
+func PatternMatches(pattern, text string) bool {
+	switch {
+	case strings.HasPrefix(pattern, GlobPrefix):
+		matched, err := path.Match(strings.TrimPrefix(pattern, GlobPrefix), text)
+		if err != nil {
+			log.Logger().Warnf("invalid glob pattern %s: %s", pattern, err.Error())
+		}
+		return matched
+	case strings.HasPrefix(pattern, RegexPrefix):
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, RegexPrefix))
+		if err != nil {
+			log.Logger().Warnf("invalid regular expression %s: %s", pattern, err.Error())
+			return false
+		}
+		return re.MatchString(text)
+	default:
+		return strings.Contains(text, pattern)
+	}
+}
+
+// LabelFilter filters resources by a kubernetes label selector
+type LabelFilter struct {
+	Selector string
+}
+
+func (o *LabelFilter) AddFlags(cmd *cobra.Command, name string) {
+	cmd.Flags().StringVarP(&o.Selector, "label-selector", "", "", "the kubernetes label selector of the "+name+" to be included when synchronising. e.g. team=frontend,tier!=cache")
+}
+
+// Validate validates the label selector
+func (o *LabelFilter) Validate() error {
+	_, err := labels.Parse(o.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid label selector %s: %w", o.Selector, err)
+	}
+	return nil
+}
+
+// Matches returns true if the labels match the selector
+func (o *LabelFilter) Matches(values map[string]string) bool {
+	if o.Selector == "" {
+		return true
+	}
+	selector, err := labels.Parse(o.Selector)
+	if err != nil {
+		log.Logger().Warnf("invalid label selector %s: %s", o.Selector, err.Error())
+		return false
+	}
+	return selector.Matches(labels.Set(values))
 }
@@ -0,0 +1,75 @@
+package gitops_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextFilterFlags(t *testing.T) {
+	f := gitops.TextFilter{}
+	cmd := &cobra.Command{}
+	f.AddFlags(cmd, "chart", "chart name")
+
+	err := cmd.Flags().Parse([]string{"--chart-include", "app1", "--chart-exclude", "app2"})
+	require.NoError(t, err, "failed to parse flags")
+
+	assert.Equal(t, []string{"app1"}, f.Includes, "includes")
+	assert.Equal(t, []string{"app2"}, f.Excludes, "excludes")
+}
+
+func TestTextFilter(t *testing.T) {
+	testCases := []struct {
+		filter     gitops.TextFilter
+		matches    []string
+		notMatches []string
+	}{
+		{
+			filter:  gitops.TextFilter{},
+			matches: []string{"", "anything"},
+		},
+		{
+			filter:     gitops.TextFilter{Includes: []string{"app1"}, Excludes: []string{"app1-old"}},
+			matches:    []string{"https://github.com/myorg/app1"},
+			notMatches: []string{"https://github.com/myorg/app2", "https://github.com/myorg/app1-old"},
+		},
+		{
+			filter:     gitops.TextFilter{Includes: []string{"glob:https://github.com/myorg/*"}},
+			matches:    []string{"https://github.com/myorg/app1"},
+			notMatches: []string{"https://github.com/other/app1", "https://github.com/myorg/app1/charts"},
+		},
+		{
+			filter:     gitops.TextFilter{Excludes: []string{"regex:^app[0-9]+$"}},
+			matches:    []string{"app", "myapp1"},
+			notMatches: []string{"app1", "app22"},
+		},
+	}
+
+	for _, tc := range testCases {
+		require.NoError(t, tc.filter.Validate(), "filter %#v should be valid", tc.filter)
+		for _, text := range tc.matches {
+			assert.True(t, tc.filter.Matches(text), "filter %#v should match %s", tc.filter, text)
+		}
+		for _, text := range tc.notMatches {
+			assert.False(t, tc.filter.Matches(text), "filter %#v should not match %s", tc.filter, text)
+		}
+	}
+
+	invalid := gitops.TextFilter{Includes: []string{"regex:app("}}
+	assert.Error(t, invalid.Validate(), "should fail to validate an invalid regular expression")
+}
+
+func TestLabelFilter(t *testing.T) {
+	f := gitops.LabelFilter{Selector: "team=frontend,tier!=cache"}
+	require.NoError(t, f.Validate(), "should be a valid selector")
+
+	assert.True(t, f.Matches(map[string]string{"team": "frontend"}), "should match")
+	assert.False(t, f.Matches(map[string]string{"team": "frontend", "tier": "cache"}), "should not match tier")
+	assert.False(t, f.Matches(nil), "should not match no labels")
+
+	empty := gitops.LabelFilter{}
+	assert.True(t, empty.Matches(nil), "empty selector should match")
+}
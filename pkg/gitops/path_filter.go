@@ -0,0 +1,180 @@
+package gitops
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// PathFilter filters the files in a git repository by their path relative to the root of the repository.
+// Patterns are globs where ** matches any number of directories. A pattern matching a directory matches all the files inside it
+type PathFilter struct {
+	Includes       []string
+	Excludes       []string
+	Dirs           []string
+	SparseCheckout bool
+}
+
+// AddFlags adds the CLI flags to this object
+func (o *PathFilter) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&o.Includes, "include-path", "", nil, "the glob patterns of the paths in the target git repository to modify. Use ** to match any number of directories. e.g. envs/staging/**")
+	cmd.Flags().StringArrayVarP(&o.Excludes, "exclude-path", "", nil, "the glob patterns of the paths in the target git repository which should not be modified. Use ** to match any number of directories")
+	cmd.Flags().BoolVarP(&o.SparseCheckout, "sparse-checkout", "", false, "only checkout the directories of the target git repository matching the --include-path patterns")
+}
+
+// Validate validates the glob patterns
+func (o *PathFilter) Validate() error {
+	for _, p := range append(append([]string{}, o.Includes...), o.Excludes...) {
+		_, err := globRegexp(p)
+		if err != nil {
+			return fmt.Errorf("invalid path glob %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// WithDirs returns a copy of the filter which only matches paths inside the given directories
+func (o *PathFilter) WithDirs(dirs []string) PathFilter {
+	answer := *o
+	answer.Dirs = dirs
+	return answer
+}
+
+// Matches returns true if the path relative to the repository matches the filter
+func (o *PathFilter) Matches(rel string) bool {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if len(o.Dirs) > 0 {
+		inside := false
+		for _, d := range o.Dirs {
+			d = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(d)), "/")
+			if d == "." || rel == d || strings.HasPrefix(rel, d+"/") {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return false
+		}
+	}
+	for _, p := range o.Excludes {
+		if MatchPath(p, rel) {
+			return false
+		}
+	}
+	if len(o.Includes) == 0 {
+		return true
+	}
+	for _, p := range o.Includes {
+		if MatchPath(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// SparseCheckoutPatterns returns the directories to checkout if sparse checkout is enabled. If an include pattern has
+// no wildcards it may be a file so its parent directory is used. If any include pattern starts with a wildcard or is
+// a file in the root directory then nil is returned so that the whole repository is checked out
+func (o *PathFilter) SparseCheckoutPatterns() []string {
+	if !o.SparseCheckout {
+		return nil
+	}
+	var answer []string
+	for _, d := range o.Dirs {
+		answer = append(answer, strings.TrimSuffix(filepath.ToSlash(d), "/")+"/")
+	}
+	if len(answer) > 0 {
+		return answer
+	}
+	for _, p := range o.Includes {
+		names := strings.Split(strings.TrimSuffix(p, "/"), "/")
+		var dirs []string
+		for _, name := range names {
+			if strings.ContainsAny(name, "*?[") {
+				break
+			}
+			dirs = append(dirs, name)
+		}
+		if len(dirs) == len(names) {
+			dirs = dirs[:len(dirs)-1]
+		}
+		if len(dirs) == 0 {
+			return nil
+		}
+		answer = append(answer, strings.Join(dirs, "/")+"/")
+	}
+	return answer
+}
+
+// ModifyFiles modifies the files in the dir like kyamls.ModifyFiles but ignores any files whose path relative to the
+// dir does not match the filter
+func (o *PathFilter) ModifyFiles(dir string, modifyFn func(node *yaml.RNode, path string) (bool, error), filter kyamls.Filter) error {
+	fn := func(node *yaml.RNode, path string) (bool, error) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return false, fmt.Errorf("failed to get relative path of %s: %w", path, err)
+		}
+		if !o.Matches(rel) {
+			return false, nil
+		}
+		return modifyFn(node, path)
+	}
+	return kyamls.ModifyFiles(dir, fn, filter)
+}
+
+// MatchPath returns true if the glob pattern matches the slash separated path or any of its parent directories
+func MatchPath(pattern, rel string) bool {
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return false
+	}
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp converts the glob pattern to a regular expression where ** matches any number of directories
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimPrefix(strings.TrimSuffix(filepath.ToSlash(pattern), "/"), "./")
+	buf := strings.Builder{}
+	buf.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			buf.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			buf.WriteString(".*")
+			i++
+		case c == '*':
+			buf.WriteString("[^/]*")
+		case c == '?':
+			buf.WriteString("[^/]")
+		case c == '[':
+			end := strings.Index(pattern[i:], "]")
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] in glob %s", pattern)
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += end
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
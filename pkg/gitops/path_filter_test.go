@@ -0,0 +1,76 @@
+package gitops_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathFilter(t *testing.T) {
+	testCases := []struct {
+		filter     gitops.PathFilter
+		matches    []string
+		notMatches []string
+	}{
+		{
+			filter:  gitops.PathFilter{},
+			matches: []string{"app.yaml", "envs/prod/app.yaml"},
+		},
+		{
+			filter:     gitops.PathFilter{Includes: []string{"envs/staging/**"}},
+			matches:    []string{"envs/staging/app.yaml", "envs/staging/apps/nested/app.yaml"},
+			notMatches: []string{"envs/prod/app.yaml", "app.yaml", "envs/staging-old/app.yaml"},
+		},
+		{
+			filter:     gitops.PathFilter{Includes: []string{"envs/staging"}},
+			matches:    []string{"envs/staging/app.yaml", "envs/staging/apps/app.yaml"},
+			notMatches: []string{"envs/prod/app.yaml"},
+		},
+		{
+			filter:     gitops.PathFilter{Includes: []string{"**/staging/*.yaml"}, Excludes: []string{"**/ignored-*"}},
+			matches:    []string{"staging/app.yaml", "clusters/eu/staging/app.yaml"},
+			notMatches: []string{"clusters/eu/staging/ignored-app.yaml", "clusters/eu/prod/app.yaml", "staging/apps/app.yaml"},
+		},
+		{
+			filter:     gitops.PathFilter{Dirs: []string{"clusters/eu"}, Excludes: []string{"**/prod"}},
+			matches:    []string{"clusters/eu/staging/app.yaml"},
+			notMatches: []string{"clusters/us/staging/app.yaml", "clusters/eu/prod/app.yaml"},
+		},
+	}
+
+	for _, tc := range testCases {
+		require.NoError(t, tc.filter.Validate(), "filter %#v", tc.filter)
+		for _, p := range tc.matches {
+			assert.True(t, tc.filter.Matches(p), "filter %#v should match %s", tc.filter, p)
+		}
+		for _, p := range tc.notMatches {
+			assert.False(t, tc.filter.Matches(p), "filter %#v should not match %s", tc.filter, p)
+		}
+	}
+
+	f := gitops.PathFilter{Includes: []string{"["}}
+	assert.Error(t, f.Validate(), "should fail for invalid glob")
+}
+
+func TestPathFilterSparseCheckoutPatterns(t *testing.T) {
+	f := gitops.PathFilter{Includes: []string{"envs/staging/**", "shared/*.yaml"}}
+	assert.Nil(t, f.SparseCheckoutPatterns(), "sparse checkout is disabled")
+
+	f.SparseCheckout = true
+	assert.Equal(t, []string{"envs/staging/", "shared/"}, f.SparseCheckoutPatterns())
+
+	f.Includes = []string{"envs/staging/app.yaml", "shared/*.yaml"}
+	assert.Equal(t, []string{"envs/staging/", "shared/"}, f.SparseCheckoutPatterns(), "should checkout the parent directory of a file")
+
+	f.Includes = []string{"app.yaml"}
+	assert.Nil(t, f.SparseCheckoutPatterns(), "should checkout everything for a file in the root directory")
+
+	f.Includes = []string{"envs/staging/**", "shared/*.yaml"}
+	f.Includes = append(f.Includes, "**/app.yaml")
+	assert.Nil(t, f.SparseCheckoutPatterns(), "should checkout everything when a pattern starts with a wildcard")
+
+	f = f.WithDirs([]string{"clusters/eu"})
+	assert.Equal(t, []string{"clusters/eu/"}, f.SparseCheckoutPatterns())
+}
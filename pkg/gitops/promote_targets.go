@@ -0,0 +1,140 @@
+package gitops
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// PromoteTarget a target git repository to create a promotion Pull Request on
+type PromoteTarget struct {
+	// GitURL the git URL of the target repository
+	GitURL string `json:"gitUrl"`
+
+	// Labels the labels to add to the Pull Request. Defaults to the --labels option
+	Labels []string `json:"labels,omitempty"`
+
+	// AutoMerge whether the Pull Request should be merged automatically. Defaults to the --auto-merge option
+	AutoMerge *bool `json:"autoMerge,omitempty"`
+
+	// Paths the directories in the repository which can be modified. Defaults to the whole repository
+	Paths []string `json:"paths,omitempty"`
+}
+
+// PromoteTargets the contents of a promotion targets file
+type PromoteTargets struct {
+	Targets []*PromoteTarget `json:"targets,omitempty"`
+}
+
+// PromoteTargetOptions the options to specify the target repositories of a promotion
+type PromoteTargetOptions struct {
+	GitURLs     []string
+	Paths       []string
+	TargetsFile string
+}
+
+// AddFlags adds the CLI flags to this object
+func (o *PromoteTargetOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&o.GitURLs, "target-git-url", "", nil, "the target git URL to create a Pull Request on. Can be specified multiple times to promote to many repositories")
+	cmd.Flags().StringArrayVarP(&o.Paths, "target-path", "", nil, "the directories in the target git repositories which can be modified. Defaults to the whole repository")
+	cmd.Flags().StringVarP(&o.TargetsFile, "targets-file", "", "", "a YAML file containing the targets to promote to with optional gitUrl, labels, autoMerge and paths for each target")
+}
+
+// Targets returns the targets from the CLI flags and the targets file defaulting any missing labels, auto merge or paths
+func (o *PromoteTargetOptions) Targets(labels []string, autoMerge bool) ([]*PromoteTarget, error) {
+	var answer []*PromoteTarget
+	for _, gitURL := range o.GitURLs {
+		if gitURL == "" {
+			return nil, options.InvalidOptionf("target-git-url", gitURL, "git URL must not be empty")
+		}
+		answer = append(answer, &PromoteTarget{GitURL: gitURL})
+	}
+	if o.TargetsFile != "" {
+		exists, err := files.FileExists(o.TargetsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if file exists %s: %w", o.TargetsFile, err)
+		}
+		if !exists {
+			return nil, options.InvalidOptionf("targets-file", o.TargetsFile, "file does not exist")
+		}
+		config := &PromoteTargets{}
+		err = yamls.LoadFile(o.TargetsFile, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load targets file %s: %w", o.TargetsFile, err)
+		}
+		for i, t := range config.Targets {
+			if t.GitURL == "" {
+				return nil, fmt.Errorf("missing gitUrl for target %d in file %s", i+1, o.TargetsFile)
+			}
+		}
+		answer = append(answer, config.Targets...)
+	}
+
+	for _, t := range answer {
+		if t.Labels == nil {
+			t.Labels = labels
+		}
+		if t.AutoMerge == nil {
+			flag := autoMerge
+			t.AutoMerge = &flag
+		}
+		if t.Paths == nil {
+			t.Paths = o.Paths
+		}
+		for _, p := range t.Paths {
+			if filepath.IsAbs(p) || strings.HasPrefix(filepath.Clean(p), "..") {
+				return nil, fmt.Errorf("invalid path %s for target %s: must be relative to the repository", p, t.GitURL)
+			}
+		}
+	}
+	return answer, nil
+}
+
+// PromoteResult the result of promoting to a target
+type PromoteResult struct {
+	Target      *PromoteTarget
+	PullRequest *scm.PullRequest
+	Error       error
+}
+
+// CreatePullRequests creates the Pull Requests on the targets concurrently using the given function. The results
+// are logged and an error is returned if promoting to any of the targets failed
+func CreatePullRequests(targets []*PromoteTarget, fn func(*PromoteTarget) (*scm.PullRequest, error)) ([]*PromoteResult, error) {
+	results := make([]*PromoteResult, len(targets))
+	wg := sync.WaitGroup{}
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t *PromoteTarget) {
+			defer wg.Done()
+			pr, err := fn(t)
+			results[i] = &PromoteResult{Target: t, PullRequest: pr, Error: err}
+		}(i, t)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		switch {
+		case r.Error != nil:
+			log.Logger().Warnf("failed to promote to %s: %s", r.Target.GitURL, r.Error.Error())
+			errs = append(errs, fmt.Errorf("failed to create Pull Request on repository %s: %w", r.Target.GitURL, r.Error))
+		case r.PullRequest != nil:
+			log.Logger().Infof("created Pull Request %s on %s", r.PullRequest.Link, r.Target.GitURL)
+		default:
+			log.Logger().Infof("no changes needed on %s", r.Target.GitURL)
+		}
+	}
+	if len(errs) > 0 {
+		return results, fmt.Errorf("failed to promote to %d of %d targets: %w", len(errs), len(targets), errors.Join(errs...))
+	}
+	return results, nil
+}
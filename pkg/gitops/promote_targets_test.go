@@ -0,0 +1,88 @@
+package gitops_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoteTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	err := os.WriteFile(path, []byte(`targets:
+- gitUrl: https://github.com/myorg/eu-repo.git
+  labels: [eu]
+  autoMerge: false
+  paths: [clusters/eu]
+- gitUrl: https://github.com/myorg/us-repo.git
+`), 0600)
+	require.NoError(t, err, "failed to save %s", path)
+
+	o := &gitops.PromoteTargetOptions{
+		GitURLs:     []string{"https://github.com/myorg/asia-repo.git"},
+		Paths:       []string{"apps"},
+		TargetsFile: path,
+	}
+	targets, err := o.Targets([]string{"promote"}, true)
+	require.NoError(t, err, "failed to load targets")
+	require.Len(t, targets, 3, "targets")
+
+	assert.Equal(t, "https://github.com/myorg/asia-repo.git", targets[0].GitURL)
+	assert.Equal(t, []string{"promote"}, targets[0].Labels)
+	assert.True(t, *targets[0].AutoMerge)
+	assert.Equal(t, []string{"apps"}, targets[0].Paths)
+
+	assert.Equal(t, []string{"eu"}, targets[1].Labels)
+	assert.False(t, *targets[1].AutoMerge)
+	assert.Equal(t, []string{"clusters/eu"}, targets[1].Paths)
+
+	assert.Equal(t, []string{"promote"}, targets[2].Labels)
+	assert.True(t, *targets[2].AutoMerge)
+
+	o = &gitops.PromoteTargetOptions{GitURLs: []string{"https://github.com/myorg/repo.git"}, Paths: []string{"../other"}}
+	_, err = o.Targets(nil, false)
+	assert.Error(t, err, "should fail for paths outside the repository")
+
+	o = &gitops.PromoteTargetOptions{GitURLs: []string{""}}
+	_, err = o.Targets(nil, false)
+	require.Error(t, err, "should fail for an empty --target-git-url")
+	assert.Contains(t, err.Error(), "target-git-url")
+
+	path = filepath.Join(t.TempDir(), "missing-git-url.yaml")
+	err = os.WriteFile(path, []byte(`targets:
+- gitUrl: https://github.com/myorg/eu-repo.git
+- labels: [us]
+`), 0600)
+	require.NoError(t, err, "failed to save %s", path)
+
+	o = &gitops.PromoteTargetOptions{TargetsFile: path}
+	_, err = o.Targets(nil, false)
+	require.Error(t, err, "should fail for a target without a gitUrl")
+	assert.Equal(t, fmt.Sprintf("missing gitUrl for target 2 in file %s", path), err.Error())
+}
+
+func TestCreatePullRequests(t *testing.T) {
+	o := &gitops.PromoteTargetOptions{
+		GitURLs: []string{"https://github.com/myorg/repo1.git", "https://github.com/myorg/repo2.git", "https://github.com/myorg/repo3.git"},
+	}
+	targets, err := o.Targets(nil, false)
+	require.NoError(t, err, "failed to create targets")
+
+	results, err := gitops.CreatePullRequests(targets, func(target *gitops.PromoteTarget) (*scm.PullRequest, error) {
+		if target.GitURL == "https://github.com/myorg/repo2.git" {
+			return nil, fmt.Errorf("no permission")
+		}
+		return &scm.PullRequest{Link: target.GitURL + "/pull/1"}, nil
+	})
+	require.Error(t, err, "should fail for repo2")
+	assert.Contains(t, err.Error(), "failed to promote to 1 of 3 targets")
+	require.Len(t, results, 3, "results")
+	assert.Equal(t, "https://github.com/myorg/repo1.git/pull/1", results[0].PullRequest.Link)
+	assert.Error(t, results[1].Error)
+	assert.Equal(t, "https://github.com/myorg/repo3.git/pull/1", results[2].PullRequest.Link)
+}
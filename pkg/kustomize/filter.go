@@ -0,0 +1,46 @@
+package kustomize
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/gitops"
+	"github.com/spf13/cobra"
+)
+
+// KustomizationFilter filter for the images and remote resources of kustomizations
+type KustomizationFilter struct {
+	Image   gitops.TextFilter
+	RepoURL gitops.TextFilter
+	Path    gitops.TextFilter
+}
+
+// MatchesImage returns true if the image in the kustomization file matches the filter
+func (o *KustomizationFilter) MatchesImage(v *ImageVersion, path string) bool {
+	return o.Image.Matches(v.Name) && o.Path.Matches(path)
+}
+
+// MatchesResource returns true if the remote resource in the kustomization file matches the filter
+func (o *KustomizationFilter) MatchesResource(r *ResourceRef, path string) bool {
+	return o.RepoURL.Matches(r.Repository) && o.Path.Matches(path)
+}
+
+// Validate validates the filter patterns
+func (o *KustomizationFilter) Validate() error {
+	for name, f := range map[string]*gitops.TextFilter{
+		"image":   &o.Image,
+		"repourl": &o.RepoURL,
+		"path":    &o.Path,
+	} {
+		err := f.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid %s filter: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (o *KustomizationFilter) AddFlags(cmd *cobra.Command) {
+	o.Image.AddFlags(cmd, "image", "image name")
+	o.RepoURL.AddFlags(cmd, "repourl", "remote resource repository URL")
+	o.Path.AddFlags(cmd, "path", "path of the kustomization file")
+}
@@ -0,0 +1,239 @@
+package kustomize
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kyamls"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// DigestPrefix the prefix of versions which are image digests rather than tags
+	DigestPrefix = "sha256:"
+)
+
+var (
+	// KustomizationFileNames the file names of kustomization files
+	KustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml"}
+
+	// resourceFields the fields of a kustomization which can contain remote resources
+	resourceFields = []string{"resources", "components", "bases"}
+)
+
+// IsKustomization returns true if the path is a kustomization file
+func IsKustomization(path string) bool {
+	return stringhelpers.StringArrayIndex(KustomizationFileNames, filepath.Base(path)) >= 0
+}
+
+// ImageVersion represents the tag or digest of an image in the images of a kustomization file
+type ImageVersion struct {
+	Name    string
+	NewName string
+	Tag     string
+	Digest  string
+}
+
+// Version returns the digest of the image if specified otherwise the tag
+func (v *ImageVersion) Version() string {
+	if v.Digest != "" {
+		return v.Digest
+	}
+	return v.Tag
+}
+
+// String returns the string summary of the image version
+func (v *ImageVersion) String() string {
+	return "image: " + v.Name + " version: " + v.Version()
+}
+
+// GetImageVersions returns the images of the kustomization
+func GetImageVersions(node *yaml.RNode, path string) ([]*ImageVersion, error) {
+	images, err := node.Pipe(yaml.Lookup("images"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find images in file %s: %w", path, err)
+	}
+	if images == nil {
+		return nil, nil
+	}
+	elements, err := images.Elements()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read images in file %s: %w", path, err)
+	}
+	var answer []*ImageVersion
+	for _, e := range elements {
+		answer = append(answer, &ImageVersion{
+			Name:    kyamls.GetStringField(e, path, "name"),
+			NewName: kyamls.GetStringField(e, path, "newName"),
+			Tag:     kyamls.GetStringField(e, path, "newTag"),
+			Digest:  kyamls.GetStringField(e, path, "digest"),
+		})
+	}
+	return answer, nil
+}
+
+// SetImageVersion sets the newTag of the images with the name or newName. If the version starts with sha256: then
+// the digest is set instead. Returns true if the kustomization was modified
+func SetImageVersion(node *yaml.RNode, path, name, version string) (bool, error) {
+	images, err := node.Pipe(yaml.Lookup("images"))
+	if err != nil {
+		return false, fmt.Errorf("failed to find images in file %s: %w", path, err)
+	}
+	if images == nil {
+		return false, nil
+	}
+	elements, err := images.Elements()
+	if err != nil {
+		return false, fmt.Errorf("failed to read images in file %s: %w", path, err)
+	}
+
+	field, other := "newTag", "digest"
+	if strings.HasPrefix(version, DigestPrefix) {
+		field, other = other, field
+	}
+	modified := false
+	for _, e := range elements {
+		if kyamls.GetStringField(e, path, "name") != name && kyamls.GetStringField(e, path, "newName") != name {
+			continue
+		}
+		if kyamls.GetStringField(e, path, field) == version && kyamls.GetStringField(e, path, other) == "" {
+			continue
+		}
+		err = e.PipeE(yaml.SetField(field, yaml.NewStringRNode(version)))
+		if err != nil {
+			return modified, fmt.Errorf("failed to set image %s %s in file %s: %w", name, field, path, err)
+		}
+		err = e.PipeE(yaml.Clear(other))
+		if err != nil {
+			return modified, fmt.Errorf("failed to clear image %s %s in file %s: %w", name, other, path, err)
+		}
+		log.Logger().Debugf("modified the image %s in file %s to %s", name, path, version)
+		modified = true
+	}
+	return modified, nil
+}
+
+// ResourceRef represents a remote git resource in a kustomization file with a ref query parameter
+// e.g. github.com/myorg/myrepo//deploy?ref=v1.2.3
+type ResourceRef struct {
+	Resource   string
+	Repository string
+	Ref        string
+}
+
+// String returns the string summary of the resource ref
+func (r *ResourceRef) String() string {
+	return "repository: " + r.Repository + " ref: " + r.Ref
+}
+
+// ParseResourceRef parses the remote resource returning nil if it has no ref query parameter
+func ParseResourceRef(resource string) *ResourceRef {
+	resource = strings.TrimSpace(resource)
+	_, query, ok := strings.Cut(resource, "?")
+	if !ok {
+		return nil
+	}
+	for _, param := range strings.Split(query, "&") {
+		if ref, ok := strings.CutPrefix(param, "ref="); ok {
+			return &ResourceRef{
+				Resource:   resource,
+				Repository: RepositoryKey(resource),
+				Ref:        ref,
+			}
+		}
+	}
+	return nil
+}
+
+// RepositoryKey returns the host and repository path of a git URL or remote resource so that they can be compared.
+// e.g. https://github.com/myorg/myrepo.git and github.com/myorg/myrepo//deploy?ref=v1 both return github.com/myorg/myrepo.
+// For other git providers the repository path ends at the // separator of the path inside the repository or at .git
+func RepositoryKey(text string) string {
+	text = strings.TrimSpace(text)
+	text, _, _ = strings.Cut(text, "?")
+	text = strings.TrimPrefix(text, "git::")
+	for _, prefix := range []string{"https://", "http://", "ssh://"} {
+		text = strings.TrimPrefix(text, prefix)
+	}
+	if rest, ok := strings.CutPrefix(text, "git@"); ok {
+		text = strings.Replace(rest, ":", "/", 1)
+	}
+	text, _, _ = strings.Cut(text, "//")
+	if i := strings.Index(text, ".git/"); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "/"), ".git")
+
+	// github repositories are always owner/name so lets remove any path inside the repository. Other git providers
+	// support nested groups (e.g. gitlab subgroups or azure devops projects) so we keep the full path
+	paths := strings.Split(text, "/")
+	if paths[0] == "github.com" && len(paths) > 3 {
+		paths = paths[:3]
+	}
+	return strings.Join(paths, "/")
+}
+
+// GetResourceRefs returns the remote resources with a ref in the resources, components or bases of the kustomization
+func GetResourceRefs(node *yaml.RNode, path string) ([]*ResourceRef, error) {
+	var answer []*ResourceRef
+	err := visitResources(node, path, func(e *yaml.RNode) {
+		r := ParseResourceRef(e.YNode().Value)
+		if r != nil {
+			answer = append(answer, r)
+		}
+	})
+	return answer, err
+}
+
+// SetResourceRef sets the ref query parameter of the remote resources in the given git repository.
+// Returns true if the kustomization was modified
+func SetResourceRef(node *yaml.RNode, path, repository, ref string) (bool, error) {
+	key := RepositoryKey(repository)
+	modified := false
+	err := visitResources(node, path, func(e *yaml.RNode) {
+		r := ParseResourceRef(e.YNode().Value)
+		if r == nil || r.Repository != key || r.Ref == ref {
+			return
+		}
+		e.YNode().Value = setQueryRef(r.Resource, ref)
+		log.Logger().Debugf("modified the resource %s in file %s to ref %s", r.Repository, path, ref)
+		modified = true
+	})
+	return modified, err
+}
+
+func visitResources(node *yaml.RNode, path string, fn func(*yaml.RNode)) error {
+	for _, field := range resourceFields {
+		resources, err := node.Pipe(yaml.Lookup(field))
+		if err != nil {
+			return fmt.Errorf("failed to find %s in file %s: %w", field, path, err)
+		}
+		if resources == nil {
+			continue
+		}
+		elements, err := resources.Elements()
+		if err != nil {
+			return fmt.Errorf("failed to read %s in file %s: %w", field, path, err)
+		}
+		for _, e := range elements {
+			fn(e)
+		}
+	}
+	return nil
+}
+
+// setQueryRef replaces the ref query parameter preserving any other parameters
+func setQueryRef(resource, ref string) string {
+	prefix, query, _ := strings.Cut(resource, "?")
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		if strings.HasPrefix(param, "ref=") {
+			params[i] = "ref=" + ref
+		}
+	}
+	return prefix + "?" + strings.Join(params, "&")
+}
@@ -0,0 +1,40 @@
+package kustomize_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/kustomize"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryKey(t *testing.T) {
+	testCases := map[string]string{
+		"https://github.com/myorg/myrepo.git":                      "github.com/myorg/myrepo",
+		"https://github.com/myorg/myrepo":                          "github.com/myorg/myrepo",
+		"git@github.com:myorg/myrepo.git":                          "github.com/myorg/myrepo",
+		"github.com/myorg/myrepo//deploy/base?ref=v1.2.3":          "github.com/myorg/myrepo",
+		"https://github.com/myorg/myrepo.git/deploy?ref=v1.2.3":    "github.com/myorg/myrepo",
+		"git::https://github.com/myorg/myrepo/deploy?ref=v1.2.3":   "github.com/myorg/myrepo",
+		"https://gitlab.com/mygroup/mysubgroup/myrepo.git":         "gitlab.com/mygroup/mysubgroup/myrepo",
+		"gitlab.com/mygroup/mysubgroup/myrepo//deploy?ref=v1":      "gitlab.com/mygroup/mysubgroup/myrepo",
+		"gitlab.com/mygroup/othersubgroup/myrepo//deploy?ref=v1":   "gitlab.com/mygroup/othersubgroup/myrepo",
+		"https://gitlab.com/mygroup/mysubgroup/myrepo.git/deploy":  "gitlab.com/mygroup/mysubgroup/myrepo",
+		"https://dev.azure.com/myorg/myproject/_git/myrepo":        "dev.azure.com/myorg/myproject/_git/myrepo",
+		"dev.azure.com/myorg/myproject/_git/myrepo//deploy?ref=v1": "dev.azure.com/myorg/myproject/_git/myrepo",
+		"dev.azure.com/myorg/myproject/_git/other//deploy?ref=v1":  "dev.azure.com/myorg/myproject/_git/other",
+	}
+	for text, expected := range testCases {
+		assert.Equal(t, expected, kustomize.RepositoryKey(text), "for %s", text)
+	}
+}
+
+func TestParseResourceRef(t *testing.T) {
+	r := kustomize.ParseResourceRef("github.com/myorg/myrepo//deploy?timeout=120&ref=v1.2.3")
+	if assert.NotNil(t, r, "should parse the resource ref") {
+		assert.Equal(t, "github.com/myorg/myrepo", r.Repository, "repository")
+		assert.Equal(t, "v1.2.3", r.Ref, "ref")
+	}
+
+	assert.Nil(t, kustomize.ParseResourceRef("../base"), "local resources have no ref")
+	assert.Nil(t, kustomize.ParseResourceRef("github.com/myorg/myrepo//deploy"), "remote resources without a ref")
+}
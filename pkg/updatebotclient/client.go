@@ -0,0 +1,34 @@
+package updatebotclient
+
+import (
+	"fmt"
+
+	"github.com/jenkins-x-plugins/jx-updatebot/pkg/client/clientset/versioned"
+	"github.com/jenkins-x/jx-kube-client/v3/pkg/kubeclient"
+)
+
+// LazyCreateUpdatebotClientAndNamespace lazy creates the updatebot client and/or the current namespace if not already defined
+func LazyCreateUpdatebotClientAndNamespace(client versioned.Interface, ns string) (versioned.Interface, string, error) {
+	if client != nil && ns != "" {
+		return client, ns, nil
+	}
+	if client == nil {
+		f := kubeclient.NewFactory()
+		cfg, err := f.CreateKubeConfig()
+		if err != nil {
+			return client, ns, fmt.Errorf("failed to get kubernetes config: %w", err)
+		}
+		client, err = versioned.NewForConfig(cfg)
+		if err != nil {
+			return client, ns, fmt.Errorf("error building updatebot clientset: %w", err)
+		}
+	}
+	if ns == "" {
+		var err error
+		ns, err = kubeclient.CurrentNamespace()
+		if err != nil {
+			return client, ns, fmt.Errorf("failed to get current kubernetes namespace: %w", err)
+		}
+	}
+	return client, ns, nil
+}